@@ -11,6 +11,9 @@ import (
 
 var DB *gorm.DB
 
+// DSN is the connection string of DB, for connections that live outside its pool
+var DSN string
+
 func ConnectDB() {
 	var err error
 
@@ -51,11 +54,11 @@ func ConnectDB() {
 	}
 
 	// Build connection string
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
+	DSN = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
 		host, user, password, dbname, port, sslmode, timezone)
 
 	// Connect to database
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(DSN), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
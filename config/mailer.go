@@ -0,0 +1,33 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"gin-project/mailer"
+)
+
+// Mailer is nil when SMTP isn't configured, email notifications are skipped then
+var Mailer mailer.Mailer
+
+func ConnectMailer() {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("SMTP_HOST not set, email notifications are disabled")
+		return
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	Mailer = mailer.NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+
+	log.Printf("Sending email through %s:%s", host, port)
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"gin-project/webpush"
+)
+
+// Push is nil when no VAPID keys are configured, push delivery is skipped then
+var Push *webpush.Client
+
+func ConnectPush() {
+	privateKey := os.Getenv("VAPID_PRIVATE_KEY")
+	if privateKey == "" {
+		log.Println("VAPID_PRIVATE_KEY not set, web push is disabled")
+		return
+	}
+
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		subject = "mailto:admin@localhost"
+	}
+
+	client, err := webpush.NewClient(os.Getenv("VAPID_PUBLIC_KEY"), privateKey, subject, nil)
+	if err != nil {
+		log.Fatal("Failed to set up web push:", err)
+	}
+	Push = client
+}
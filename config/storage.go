@@ -0,0 +1,48 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"gin-project/storage"
+)
+
+var Storage storage.Storage
+
+func ConnectStorage() {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+
+	switch driver {
+	case "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+
+		localStorage, err := storage.NewLocalStorage(dir)
+		if err != nil {
+			log.Fatal("Failed to set up local storage:", err)
+		}
+		Storage = localStorage
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+
+		Storage = storage.NewS3Storage(
+			os.Getenv("S3_ENDPOINT"),
+			region,
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+		)
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q", driver)
+	}
+
+	log.Printf("Using %s storage for uploads", driver)
+}
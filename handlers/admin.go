@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListOutboxEntries lists outbox entries newest first, dead letters by default
+func ListOutboxEntries(c *gin.Context) {
+	cursorParams := utils.GetCursorParams(c)
+
+	query := config.DB.Model(&models.OutboxEntry{}).Where("status = ?", c.DefaultQuery("status", models.OutboxDead))
+	if topic := c.Query("topic"); topic != "" {
+		query = query.Where("topic = ?", topic)
+	}
+
+	sort := utils.KeysetSort{Column: "outbox_entries.created_at", IDColumn: "outbox_entries.id", Desc: true}
+	entries, paginationResult, err := utils.KeysetPaginate(query, cursorParams, sort, func(e models.OutboxEntry) (any, any) {
+		return e.CreatedAt, e.ID
+	})
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch outbox entries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Outbox entries fetched successfully",
+		"data":       entries,
+		"pagination": paginationResult,
+	})
+}
+
+// RetryOutboxEntry queues a dead entry for delivery again
+func RetryOutboxEntry(c *gin.Context) {
+	entryID, err := strconv.ParseUint(c.Param("entryID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid outbox entry ID",
+		})
+		return
+	}
+
+	retried, err := utils.RetryOutboxEntry(uint(entryID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to retry outbox entry",
+		})
+		return
+	}
+	if !retried {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Dead outbox entry not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Outbox entry queued for retry",
+	})
+}
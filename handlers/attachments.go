@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/storage"
+	"gin-project/utils"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxUploadSize reads MAX_UPLOAD_SIZE_MB, defaulting to 10MB
+func maxUploadSize() int64 {
+	if sizeMB, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_SIZE_MB"), 10, 64); err == nil && sizeMB > 0 {
+		return sizeMB << 20
+	}
+	return 10 << 20
+}
+
+func UploadAttachment(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if !utils.IsRoomMember(roomID, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	limit := maxUploadSize()
+	// Leave some room for the multipart envelope around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "A file is required in the \"file\" field",
+		})
+		return
+	}
+
+	if fileHeader.Size > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": fmt.Sprintf("File must be at most %d MB", limit>>20),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Failed to read uploaded file",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil || int64(len(data)) > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"message": fmt.Sprintf("File must be at most %d MB", limit>>20),
+		})
+		return
+	}
+
+	// Trust the file contents rather than the client supplied Content-Type
+	contentType := mimetype.Detect(data).String()
+
+	attachment := models.Attachment{
+		ID:          uuid.New().String(),
+		RoomID:      roomID,
+		UploaderID:  currentUserID,
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	attachment.StorageKey = fmt.Sprintf("attachments/%s/%s", roomID, attachment.ID)
+
+	ctx := c.Request.Context()
+	if err := config.Storage.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		log.Printf("Failed to store attachment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to store file",
+		})
+		return
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnail, width, height, err := utils.GenerateThumbnail(data)
+		attachment.Width, attachment.Height = width, height
+		if err == nil {
+			thumbnailKey := attachment.StorageKey + "_thumb"
+			if err := config.Storage.Put(ctx, thumbnailKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/jpeg"); err == nil {
+				attachment.ThumbnailKey = &thumbnailKey
+				attachment.HasThumbnail = true
+			} else {
+				log.Printf("Failed to store thumbnail: %v", err)
+			}
+		}
+	}
+
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		utils.DeleteAttachmentFiles(ctx, []models.Attachment{attachment})
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to save attachment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "File uploaded successfully",
+		"data":    attachment,
+	})
+}
+
+func DownloadAttachment(c *gin.Context) {
+	serveAttachment(c, false)
+}
+
+func DownloadThumbnail(c *gin.Context) {
+	serveAttachment(c, true)
+}
+
+// serveAttachment streams an attachment or its thumbnail to a member of its room
+func serveAttachment(c *gin.Context, thumbnail bool) {
+	currentUserID := c.GetUint("userID")
+	attachmentID := c.Param("attachmentID")
+
+	var attachment models.Attachment
+	if err := config.DB.First(&attachment, "id = ?", attachmentID).Error; err != nil || !utils.IsRoomMember(attachment.RoomID, currentUserID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Attachment not found",
+		})
+		return
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail {
+		if attachment.ThumbnailKey == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Attachment has no thumbnail",
+			})
+			return
+		}
+		key, contentType = *attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	body, err := config.Storage.Get(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Attachment file is missing",
+		})
+		return
+	} else if err != nil {
+		log.Printf("Failed to read attachment %s: %v", attachment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to read attachment",
+		})
+		return
+	}
+	defer body.Close()
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.FileName))
+	c.DataFromReader(http.StatusOK, -1, contentType, body, nil)
+}
@@ -8,6 +8,7 @@ import (
 	"gin-project/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func Register(c *gin.Context) {
@@ -34,14 +35,22 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// New accounts get daily digests, accounts that predate digests stay opted out
 	user := models.User{
-		Name:     req.Name,
-		Email:    req.Email,
-		Age:      req.Age,
-		Password: hashedPassword,
+		Name:               req.Name,
+		Email:              req.Email,
+		Age:                req.Age,
+		Password:           hashedPassword,
+		EmailNotifications: models.EmailDaily,
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return utils.EnqueueWebhookEvent(tx, utils.WebhookUserRegistered, nil, user)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Failed to create user",
 		})
@@ -62,7 +71,7 @@ func Register(c *gin.Context) {
 		"message": "User registered successfully",
 		"data": models.AuthResponse{
 			Token: token,
-			User:  user,
+			User:  models.NewOwnProfile(user),
 		},
 	})
 }
@@ -75,7 +84,7 @@ func Login(c *gin.Context) {
 		return
 	}
 	var user models.User
-	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := config.DB.Where("email = ? AND NOT is_bot", req.Email).First(&user).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "Invalid email or password",
 		})
@@ -103,7 +112,7 @@ func Login(c *gin.Context) {
 		"message": "Login successful",
 		"data": models.AuthResponse{
 			Token: token,
-			User:  user,
+			User:  models.NewOwnProfile(user),
 		},
 	})
 }
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func ListBots(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var users []models.User
+	if err := config.DB.Where("is_bot AND bot_owner_id = ?", currentUserID).Order("created_at DESC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch bots",
+		})
+		return
+	}
+
+	botIDs := make([]uint, len(users))
+	for i, user := range users {
+		botIDs[i] = user.ID
+	}
+	urls, err := utils.BotWebhookURLs(botIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch bots",
+		})
+		return
+	}
+
+	bots := make([]models.Bot, len(users))
+	for i, user := range users {
+		bots[i] = models.Bot{User: user}
+		if url, ok := urls[user.ID]; ok {
+			bots[i].WebhookURL = &url
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bots fetched successfully",
+		"data":    bots,
+	})
+}
+
+// CreateBot creates a bot owned by the current user. Its token, and the webhook secret when a
+// webhook URL is given, are only returned here.
+func CreateBot(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.CreateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	token, tokenHash, err := utils.GenerateBotToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to generate bot token",
+		})
+		return
+	}
+	handle, err := utils.GenerateRandomToken(12)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to generate bot token",
+		})
+		return
+	}
+
+	// Bots have no password and a placeholder address, they can't log in or receive email
+	bot := models.User{
+		Name:               req.Name,
+		Email:              fmt.Sprintf("bot-%s@bots.invalid", handle),
+		IsBot:              true,
+		BotOwnerID:         &currentUserID,
+		EmailNotifications: models.EmailOff,
+	}
+
+	var webhookSecret string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&bot).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.BotToken{BotID: bot.ID, TokenHash: tokenHash}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		webhookSecret, err = utils.SetBotWebhook(tx, bot.ID, req.WebhookURL)
+		return err
+	})
+	if errors.Is(err, utils.ErrUnsafeWebhookURL) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Webhook URL must be a public http or https address",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create bot",
+		})
+		return
+	}
+
+	data := models.Bot{User: bot}
+	response := gin.H{
+		"message": "Bot created successfully",
+		"token":   token,
+	}
+	if req.WebhookURL != "" {
+		data.WebhookURL = &req.WebhookURL
+		response["webhook_secret"] = webhookSecret
+	}
+	response["data"] = data
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func UpdateBot(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	botID := c.Param("botID")
+
+	var req models.UpdateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var bot models.User
+	if err := config.DB.First(&bot, "id = ? AND is_bot AND bot_owner_id = ?", botID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Bot not found",
+		})
+		return
+	}
+
+	var webhookSecret string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Name != nil {
+			bot.Name = *req.Name
+			if err := tx.Model(&bot).Update("name", bot.Name).Error; err != nil {
+				return err
+			}
+		}
+		if req.WebhookURL == nil {
+			return nil
+		}
+
+		var err error
+		webhookSecret, err = utils.SetBotWebhook(tx, bot.ID, *req.WebhookURL)
+		return err
+	})
+	if errors.Is(err, utils.ErrUnsafeWebhookURL) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Webhook URL must be a public http or https address",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to update bot",
+		})
+		return
+	}
+
+	urls, _ := utils.BotWebhookURLs([]uint{bot.ID})
+	data := models.Bot{User: bot}
+	if url, ok := urls[bot.ID]; ok {
+		data.WebhookURL = &url
+	}
+	response := gin.H{
+		"message": "Bot updated successfully",
+		"data":    data,
+	}
+	if webhookSecret != "" {
+		response["webhook_secret"] = webhookSecret
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RegenerateBotToken replaces the bot's token, the old one stops working right away
+func RegenerateBotToken(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	botID := c.Param("botID")
+
+	var bot models.User
+	if err := config.DB.First(&bot, "id = ? AND is_bot AND bot_owner_id = ?", botID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Bot not found",
+		})
+		return
+	}
+
+	token, tokenHash, err := utils.GenerateBotToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to generate bot token",
+		})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bot_id = ?", bot.ID).Delete(&models.BotToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.BotToken{BotID: bot.ID, TokenHash: tokenHash}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to regenerate bot token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bot token regenerated successfully",
+		"token":   token,
+	})
+}
+
+// DeleteBot removes the bot from its rooms and deletes it with its token and webhook
+func DeleteBot(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	botID := c.Param("botID")
+
+	var bot models.User
+	if err := config.DB.First(&bot, "id = ? AND is_bot AND bot_owner_id = ?", botID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Bot not found",
+		})
+		return
+	}
+
+	var roomIDs []string
+	config.DB.Model(&models.RoomMember{}).Where("user_id = ?", bot.ID).Pluck("room_id", &roomIDs)
+
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", bot.ID).Delete(&models.RoomMember{}).Error; err != nil {
+			return err
+		}
+		for _, roomID := range roomIDs {
+			event := utils.Event{Type: "member.left", Data: gin.H{"user_id": bot.ID}}
+			if err := utils.EnqueueRoomEvent(tx, roomID, event); err != nil {
+				return err
+			}
+			_, err := utils.CreateSystemMessageTx(tx, roomID, bot.ID, utils.SystemMessageMemberLeft,
+				map[string]any{"actor_id": bot.ID, "user_id": bot.ID},
+				fmt.Sprintf("%s left the room", bot.Name))
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := utils.SetBotWebhook(tx, bot.ID, ""); err != nil {
+			return err
+		}
+		if err := tx.Where("bot_id = ?", bot.ID).Delete(&models.BotToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&bot).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to delete bot",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bot deleted successfully",
+	})
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +12,7 @@ import (
 	"gin-project/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func HasRoom(c *gin.Context) {
@@ -69,9 +70,18 @@ func RequestChat(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"message": "Target user not found",
 		})
+		return
+	}
+
+	// Bots join rooms through their owner, not through chat requests
+	if targetUser.IsBot {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Bots cannot receive chat requests",
+		})
+		return
 	}
 
-	requestExists := config.DB.Where("sender_id = ? AND receiver_id = ? AND status = ? OR status = ?", currentUserID, targetUserIDUint, "pending", "accepted").First(&models.ChatRequest{})
+	requestExists := config.DB.Where("sender_id = ? AND receiver_id = ? AND status IN ?", currentUserID, targetUserIDUint, []string{"pending", "accepted"}).First(&models.ChatRequest{})
 	if requestExists.Error == nil {
 		c.JSON(http.StatusConflict, gin.H{
 			"message": "Chat request already sent to this user",
@@ -84,7 +94,17 @@ func RequestChat(c *gin.Context) {
 		ReceiverID: targetUserIDUint,
 	}
 
-	if err := config.DB.Create(&chat_request).Error; err != nil {
+	// The request and the receiver's notification about it are written together
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&chat_request).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueChatRequestWebhook(tx, utils.WebhookChatRequestCreated, chat_request); err != nil {
+			return err
+		}
+		return utils.NotifyChatRequestReceived(tx, chat_request)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Failed to create chat request",
 		})
@@ -181,35 +201,90 @@ func RespondToChatRequest(c *gin.Context) {
 
 	log.Printf("Responding to chat request: %s with accept = %t", chatRequest.ID, req.Accept)
 
+	// The request is answered either way, so the receiver's notification about it is done
+	answer := func(tx *gorm.DB) error {
+		if err := tx.Save(&chatRequest).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueChatRequestWebhook(tx, "chat_request."+chatRequest.Status, chatRequest); err != nil {
+			return err
+		}
+		return utils.ResolveNotifications(tx, currentUserID, utils.ChatRequestNotificationKey(chatRequest.SenderID))
+	}
+
 	if !req.Accept {
 		log.Println("Rejecting chat request")
 		chatRequest.Status = "rejected"
 		chatRequest.UpdatedAt = utils.GetCurrentTimestamp()
-		config.DB.Save(&chatRequest)
+
+		err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+			if err := answer(tx); err != nil || !utils.NotifyRejectedChatRequests() {
+				return err
+			}
+			_, err := utils.CreateNotificationTx(tx, chatRequest.SenderID, utils.ChatRequestRejectedPayload{
+				ChatRequestID: chatRequest.ID,
+				ReceiverID:    chatRequest.ReceiverID,
+				ReceiverName:  chatRequest.Receiver.Name,
+			})
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Failed to reject chat request",
+			})
+			return
+		}
 	} else {
 		log.Println("Creating room between users")
 		chatRequest.Status = "accepted"
 		chatRequest.UpdatedAt = utils.GetCurrentTimestamp()
-		config.DB.Save(&chatRequest)
 
-		// Create room between users
-		roomResult := utils.CreateRoomBetweenUsers(chatRequest.SenderID, chatRequest.ReceiverID)
-		if !roomResult.Success || roomResult.Room == nil {
+		var roomResult models.RoomCreateResult
+		err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+			if err := answer(tx); err != nil {
+				return err
+			}
+
+			roomResult = utils.CreateRoomBetweenUsers(tx, chatRequest.SenderID, chatRequest.ReceiverID)
+			if !roomResult.Success || roomResult.Room == nil {
+				return errors.New(roomResult.Error)
+			}
+
+			if roomResult.Error == "" {
+				_, err := utils.CreateSystemMessageTx(tx, roomResult.Room.ID, chatRequest.ReceiverID, utils.SystemMessageRoomCreated,
+					map[string]any{
+						"room_type": models.RoomTypeDirect,
+						"actor_id":  chatRequest.ReceiverID,
+						"user_ids":  []uint{chatRequest.SenderID, chatRequest.ReceiverID},
+					},
+					fmt.Sprintf("%s accepted the chat request from %s", chatRequest.Receiver.Name, chatRequest.Sender.Name))
+				if err != nil {
+					return err
+				}
+			}
+
+			// Create notification for the sender
+			_, err := utils.CreateNotificationTx(tx, chatRequest.SenderID, utils.ChatRequestAcceptedPayload{
+				ChatRequestID: chatRequest.ID,
+				RoomID:        roomResult.Room.ID,
+				ReceiverID:    chatRequest.ReceiverID,
+				ReceiverName:  chatRequest.Receiver.Name,
+			})
+			return err
+		})
+		if err != nil && roomResult.Error != "" && !roomResult.Success {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": "Failed to create room after accepting chat request",
 				"error":   roomResult.Error,
 			})
 			return
 		}
-
-		// Create notification for the receiver
-		notification := models.Notification{
-			UserID:   chatRequest.SenderID,
-			Message:  fmt.Sprintf("Your chat request to %s has been accepted.", chatRequest.Receiver.Name),
-			Metadata: json.RawMessage(fmt.Sprintf(`{"room_id": "%s"}`, roomResult.Room.ID)),
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Failed to accept chat request",
+			})
+			return
 		}
-		config.DB.Create(&notification)
-		log.Printf("Notification created successfully for user: %d", notification.UserID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -219,33 +294,133 @@ func RespondToChatRequest(c *gin.Context) {
 
 }
 
+func CancelChatRequest(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	chatRequestID := c.Param("requestID")
+
+	var chatRequest models.ChatRequest
+	if err := config.DB.Preload("Sender").Preload("Receiver").First(&chatRequest, "id = ? AND sender_id = ?", chatRequestID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Chat request not found",
+		})
+		return
+	}
+
+	if chatRequest.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Chat request is not pending",
+		})
+		return
+	}
+
+	chatRequest.Status = "cancelled"
+	chatRequest.UpdatedAt = utils.GetCurrentTimestamp()
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&chatRequest).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueChatRequestWebhook(tx, utils.WebhookChatRequestCancelled, chatRequest); err != nil {
+			return err
+		}
+		return utils.NotifyChatRequestCancelled(tx, chatRequest)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to cancel chat request",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Chat request cancelled successfully",
+		"data":    chatRequest,
+	})
+}
+
 func ListChatRooms(c *gin.Context) {
 	currentUserID := c.GetUint("userID")
-	paginationParams := utils.GetPaginationParams(c)
+	cursorParams := utils.GetCursorParams(c)
+
+	now := utils.GetCurrentTimestamp()
+	query := config.DB.Model(&models.Room{}).Preload("Members").
+		Joins("JOIN room_members AS rm ON rm.room_id = rooms.id AND rm.user_id = ?", currentUserID).
+		Where("rm.archived = ?", c.Query("archived") == "true").
+		// A conversation deleted for the user stays hidden until someone writes again
+		Where("(rm.cleared_at IS NULL OR rooms.last_activity_at > rm.cleared_at)")
+
+	switch c.Query("muted") {
+	case "true":
+		query = query.Where("rm.muted_until > ?", now)
+	case "false":
+		query = query.Where("(rm.muted_until IS NULL OR rm.muted_until <= ?)", now)
+	}
 
-	query := config.DB.Model(&models.Room{}).Preload("Members").Where("id IN (?)",
-		config.DB.Table("room_members").Select("room_id").Where("user_id = ?", currentUserID),
-	)
-	paginatedQuery, paginationResult := utils.Paginate(query, paginationParams)
+	sort := utils.KeysetSort{Column: "rooms.last_activity_at", IDColumn: "rooms.id", Desc: true}
+	key := func(r models.Room) (any, any) { return r.LastActivityAt, r.ID }
+	if c.Query("sort") == "created_at" {
+		sort.Column = "rooms.created_at"
+		key = func(r models.Room) (any, any) { return r.CreatedAt, r.ID }
+	}
 
-	var rooms []models.Room
-	if err := paginatedQuery.Find(&rooms).Error; err != nil {
-		fmt.Println(err)
+	rooms, paginationResult, err := utils.KeysetPaginate(query, cursorParams, sort, key)
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to list chat rooms for user %d: %v", currentUserID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Failed to fetch chat rooms",
 		})
 		return
 	}
 
-	var roomsWithLastMessage []models.RoomWithLastMessage
+	roomIDs := make([]string, 0, len(rooms))
+	var lastMessageIDs []string
 	for _, room := range rooms {
-		roomWithMsg := models.RoomWithLastMessage{Room: room}
-		var lastMessage models.Message
-		err := config.DB.Where("room_id = ?", room.ID).Order("created_at DESC").First(&lastMessage).Error
-		if err == nil {
-			roomWithMsg.LastMessage = &lastMessage
+		roomIDs = append(roomIDs, room.ID)
+		if room.LastMessageID != nil {
+			lastMessageIDs = append(lastMessageIDs, *room.LastMessageID)
 		}
+	}
+
+	var settings []models.RoomMember
+	config.DB.Where("user_id = ? AND room_id IN ?", currentUserID, roomIDs).Find(&settings)
+	settingsByRoom := make(map[string]*models.RoomMember, len(settings))
+	for i := range settings {
+		settingsByRoom[settings[i].RoomID] = &settings[i]
+	}
+
+	// Last messages for the whole page in one query, deleted ones come back as tombstones
+	var lastMessages []models.Message
+	if len(lastMessageIDs) > 0 {
+		config.DB.Unscoped().Preload("Sender").Preload("Attachments").Where("id IN ?", lastMessageIDs).Find(&lastMessages)
+	}
+	if err := utils.AttachReactionsToList(lastMessages, currentUserID); err != nil {
+		log.Printf("Failed to load reactions: %v", err)
+	}
+	lastMessagesByID := make(map[string]*models.Message, len(lastMessages))
+	for i := range lastMessages {
+		lastMessagesByID[lastMessages[i].ID] = &lastMessages[i]
+	}
+
+	unreadCounts, err := utils.GetUnreadCounts(currentUserID, roomIDs)
+	if err != nil {
+		log.Printf("Failed to load unread counts: %v", err)
+	}
 
+	roomsWithLastMessage := make([]models.RoomWithLastMessage, 0, len(rooms))
+	for _, room := range rooms {
+		roomWithMsg := models.RoomWithLastMessage{
+			Room:        room,
+			UnreadCount: unreadCounts[room.ID],
+			Settings:    settingsByRoom[room.ID],
+		}
+		if room.LastMessageID != nil {
+			roomWithMsg.LastMessage = lastMessagesByID[*room.LastMessageID]
+		}
 		roomsWithLastMessage = append(roomsWithLastMessage, roomWithMsg)
 	}
 
@@ -255,3 +430,139 @@ func ListChatRooms(c *gin.Context) {
 		"pagination": paginationResult,
 	})
 }
+
+func MarkRoomRead(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.MarkRoomReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var member models.RoomMember
+	if err := config.DB.First(&member, "room_id = ? AND user_id = ?", roomID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ? AND room_id = ?", req.MessageID, roomID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	// The read pointer only ever moves forward
+	if member.LastReadAt != nil && !message.CreatedAt.After(*member.LastReadAt) {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Room already read up to this message",
+			"data":    member,
+		})
+		return
+	}
+
+	member.LastReadMessageID = &message.ID
+	member.LastReadAt = &message.CreatedAt
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RoomMember{}).Where("room_id = ? AND user_id = ?", roomID, currentUserID).Updates(map[string]any{
+			"last_read_message_id": member.LastReadMessageID,
+			"last_read_at":         member.LastReadAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return utils.EnqueueRoomEvent(tx, roomID, utils.Event{Type: "message.read", Data: member})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to mark room as read",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Room marked as read",
+		"data":    member,
+	})
+}
+
+func ListReadReceipts(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if !utils.IsRoomMember(roomID, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	var members []models.RoomMember
+	if err := config.DB.Where("room_id = ?", roomID).Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch read receipts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Read receipts fetched successfully",
+		"data":    members,
+	})
+}
+
+func UpdateRoomSettings(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.UpdateRoomSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var member models.RoomMember
+	if err := config.DB.First(&member, "room_id = ? AND user_id = ?", roomID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	updates := map[string]any{}
+	if req.Unmute {
+		updates["muted_until"] = nil
+	} else if req.MutedUntil != nil {
+		updates["muted_until"] = req.MutedUntil.UTC()
+	}
+	if req.Archived != nil {
+		updates["archived"] = *req.Archived
+	}
+	if req.NotificationLevel != nil {
+		updates["notification_level"] = *req.NotificationLevel
+	}
+
+	if len(updates) > 0 {
+		err := config.DB.Model(&models.RoomMember{}).Where("room_id = ? AND user_id = ?", roomID, currentUserID).Updates(updates).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Failed to update room settings",
+			})
+			return
+		}
+	}
+
+	config.DB.First(&member, "room_id = ? AND user_id = ?", roomID, currentUserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Room settings updated successfully",
+		"data":    member,
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"io"
+	"time"
+
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func StreamEvents(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	events, first := utils.Hub.Subscribe(currentUserID)
+	if first {
+		utils.UserConnected(currentUserID)
+	}
+	defer func() {
+		if utils.Hub.Unsubscribe(currentUserID, events) {
+			utils.UserDisconnected(currentUserID)
+		}
+	}()
+
+	// Keep the connection alive through proxies that drop idle streams
+	keepAlive := time.NewTicker(25 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("ping", gin.H{})
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInviteUnavailable   = errors.New("invite has expired, been revoked or has no uses left")
+	errJoinRequestAnswered = errors.New("join request was already answered")
+	errAlreadyMember       = errors.New("user is already a member of the room")
+)
+
+// requireGroupModerator loads the room and checks the user may manage it, writing the error response if not
+func requireGroupModerator(c *gin.Context, roomID string, userID uint) (*models.Room, bool) {
+	room, member, err := utils.GetRoomMember(roomID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return nil, false
+	}
+
+	if room.Type != models.RoomTypeGroup || !member.CanModerate(room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only admins of a group room can manage invites",
+		})
+		return nil, false
+	}
+
+	return room, true
+}
+
+func CreateInvite(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if _, ok := requireGroupModerator(c, roomID, currentUserID); !ok {
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(utils.GetCurrentTimestamp()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "expires_at must be in the future",
+		})
+		return
+	}
+
+	token, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create invite",
+		})
+		return
+	}
+
+	invite := models.RoomInvite{
+		RoomID:      roomID,
+		CreatedByID: currentUserID,
+		Token:       token,
+		MaxUses:     req.MaxUses,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if err := config.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create invite",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Invite created successfully",
+		"data":    invite,
+	})
+}
+
+func ListInvites(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if _, ok := requireGroupModerator(c, roomID, currentUserID); !ok {
+		return
+	}
+
+	var invites []models.RoomInvite
+	err := config.DB.Where("room_id = ? AND revoked_at IS NULL", roomID).
+		Where("(expires_at IS NULL OR expires_at > ?)", utils.GetCurrentTimestamp()).
+		Where("(max_uses IS NULL OR uses < max_uses)").
+		Order("created_at DESC").
+		Find(&invites).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch invites",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invites fetched successfully",
+		"data":    invites,
+	})
+}
+
+func RevokeInvite(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if _, ok := requireGroupModerator(c, roomID, currentUserID); !ok {
+		return
+	}
+
+	result := config.DB.Model(&models.RoomInvite{}).
+		Where("id = ? AND room_id = ? AND revoked_at IS NULL", c.Param("inviteID"), roomID).
+		Update("revoked_at", utils.GetCurrentTimestamp())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to revoke invite",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invite not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Invite revoked successfully",
+	})
+}
+
+func JoinWithInvite(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	authUser := c.MustGet("authUser").(models.User)
+
+	var invite models.RoomInvite
+	if err := config.DB.First(&invite, "token = ?", c.Param("token")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invite not found",
+		})
+		return
+	}
+
+	var room models.Room
+	if err := config.DB.First(&room, "id = ?", invite.RoomID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Invite not found",
+		})
+		return
+	}
+
+	if utils.IsRoomMember(room.ID, currentUserID) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "You are already a member of this room",
+			"room_id": room.ID,
+		})
+		return
+	}
+
+	if room.RequireApproval {
+		var pending int64
+		config.DB.Model(&models.JoinRequest{}).Where("room_id = ? AND user_id = ? AND status = ?", room.ID, currentUserID, "pending").Count(&pending)
+		if pending > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Your request to join is already pending",
+			})
+			return
+		}
+	}
+
+	var joinRequest models.JoinRequest
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := lockRoomForJoin(tx, room.ID); err != nil {
+			return err
+		}
+		usable := tx.Model(&models.RoomInvite{}).
+			Where("id = ? AND revoked_at IS NULL", invite.ID).
+			Where("(expires_at IS NULL OR expires_at > ?)", utils.GetCurrentTimestamp())
+
+		if !room.RequireApproval {
+			if err := claimInviteUse(usable); err != nil {
+				return err
+			}
+			return addMemberFromInvite(tx, room, currentUserID, authUser.Name)
+		}
+
+		// A use is only counted once the request is approved
+		var available int64
+		if err := usable.Where("(max_uses IS NULL OR uses < max_uses)").Count(&available).Error; err != nil {
+			return err
+		}
+		if available == 0 {
+			return errInviteUnavailable
+		}
+
+		joinRequest = models.JoinRequest{
+			RoomID:   room.ID,
+			UserID:   currentUserID,
+			InviteID: invite.ID,
+		}
+		if err := tx.Create(&joinRequest).Error; err != nil {
+			return err
+		}
+		for _, moderatorID := range utils.GetRoomModeratorIDs(room.ID) {
+			_, err := utils.CreateNotificationTx(tx, moderatorID, utils.JoinRequestReceivedPayload{
+				RoomID:        room.ID,
+				RoomName:      room.Name,
+				JoinRequestID: joinRequest.ID,
+				UserID:        currentUserID,
+				UserName:      authUser.Name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errInviteUnavailable) || errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusGone, gin.H{
+			"message": "This invite has expired or been revoked",
+		})
+		return
+	}
+	if errors.Is(err, errAlreadyMember) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "You are already a member of this room",
+			"room_id": room.ID,
+		})
+		return
+	}
+	if err != nil && room.RequireApproval {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create join request",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to join room",
+		})
+		return
+	}
+
+	if room.RequireApproval {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Join request sent, waiting for an admin to approve it",
+			"data":    joinRequest,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Joined room successfully",
+		"data":    room,
+	})
+}
+
+func ListJoinRequests(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if _, ok := requireGroupModerator(c, roomID, currentUserID); !ok {
+		return
+	}
+
+	paginationParams := utils.GetPaginationParams(c)
+
+	query := config.DB.Model(&models.JoinRequest{}).Preload("User").Where("room_id = ? AND status = ?", roomID, "pending").Order("created_at ASC")
+	paginatedQuery, paginationResult := utils.Paginate(query, paginationParams)
+
+	var joinRequests []models.JoinRequest
+	if err := paginatedQuery.Find(&joinRequests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch join requests",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Join requests fetched successfully",
+		"data":       joinRequests,
+		"pagination": paginationResult,
+	})
+}
+
+func RespondToJoinRequest(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.AcceptOrRejectChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var joinRequest models.JoinRequest
+	if err := config.DB.Preload("User").First(&joinRequest, "id = ?", c.Param("requestID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Join request not found",
+		})
+		return
+	}
+
+	room, ok := requireGroupModerator(c, joinRequest.RoomID, currentUserID)
+	if !ok {
+		return
+	}
+
+	if joinRequest.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Join request is not pending",
+		})
+		return
+	}
+
+	joinRequest.Status = "rejected"
+	if req.Accept {
+		joinRequest.Status = "accepted"
+	}
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := lockRoomForJoin(tx, room.ID); err != nil {
+			return err
+		}
+		result := tx.Model(&joinRequest).Where("status = ?", "pending").Update("status", joinRequest.Status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errJoinRequestAnswered
+		}
+		if !req.Accept {
+			return nil
+		}
+
+		// The request was made while the invite was valid, only its use limit still applies
+		if err := claimInviteUse(tx.Model(&models.RoomInvite{}).Where("id = ?", joinRequest.InviteID)); err != nil {
+			return err
+		}
+		if err := addMemberFromInvite(tx, *room, joinRequest.UserID, joinRequest.User.Name); err != nil {
+			return err
+		}
+		_, err := utils.CreateNotificationTx(tx, joinRequest.UserID, utils.JoinRequestAcceptedPayload{
+			RoomID:   room.ID,
+			RoomName: room.Name,
+		})
+		return err
+	})
+	if errors.Is(err, errJoinRequestAnswered) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Join request is not pending",
+		})
+		return
+	}
+	if errors.Is(err, errInviteUnavailable) {
+		c.JSON(http.StatusGone, gin.H{
+			"message": "The invite has no uses left",
+		})
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+	if errors.Is(err, errAlreadyMember) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "User is already a member of this room",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to respond to join request",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Join request %s successfully", joinRequest.Status),
+		"data":    joinRequest,
+	})
+}
+
+// lockRoomForJoin share locks the room row for the rest of tx. Joins don't block each other, but
+// they wait for a leave that may delete the room and find it gone afterwards.
+func lockRoomForJoin(tx *gorm.DB, roomID string) error {
+	return tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id").First(&models.Room{}, "id = ?", roomID).Error
+}
+
+// claimInviteUse counts a use of the invite selected by query, atomically so concurrent joins
+// cannot exceed max_uses
+func claimInviteUse(query *gorm.DB) error {
+	claim := query.Where("(max_uses IS NULL OR uses < max_uses)").Update("uses", gorm.Expr("uses + 1"))
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return errInviteUnavailable
+	}
+	return nil
+}
+
+// addMemberFromInvite adds the user to the room and announces it in tx. Someone who became a member
+// in the meantime, through another invite or by being added, gets errAlreadyMember.
+func addMemberFromInvite(tx *gorm.DB, room models.Room, userID uint, userName string) error {
+	member := models.RoomMember{RoomID: room.ID, UserID: userID, Role: models.RoleMember}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errAlreadyMember
+	}
+
+	if err := utils.EnqueueRoomEvent(tx, room.ID, utils.Event{
+		Type: "member.added",
+		Data: gin.H{"user_ids": []uint{userID}},
+	}); err != nil {
+		return err
+	}
+	_, err := utils.CreateSystemMessageTx(tx, room.ID, userID, utils.SystemMessageMemberJoined,
+		map[string]any{"actor_id": userID, "user_id": userID, "via": "invite"},
+		fmt.Sprintf("%s joined the room", userName))
+	return err
+}
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errAttachmentsClaimed = errors.New("attachments were already sent")
+
+func SendMessage(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if !utils.IsRoomMember(roomID, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	message := models.Message{
+		RoomID:          roomID,
+		SenderID:        currentUserID,
+		Type:            models.MessageTypeUser,
+		Content:         req.Content,
+		QuotedMessageID: req.QuotedMessageID,
+		Mentions:        utils.MentionRoomMembers(roomID, currentUserID, req.Content),
+	}
+
+	var parent models.Message
+	if req.ParentID != nil {
+		if err := config.DB.First(&parent, "id = ? AND room_id = ?", *req.ParentID, roomID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Parent message not found in this room",
+			})
+			return
+		}
+		// Replying to a reply continues the same thread, even when its root was deleted since
+		if parent.ParentID != nil {
+			var root models.Message
+			if err := config.DB.Unscoped().First(&root, "id = ?", *parent.ParentID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"message": "Parent message not found in this room",
+				})
+				return
+			}
+			parent = root
+		}
+		message.ParentID = &parent.ID
+	}
+
+	if strings.TrimSpace(req.Content) == "" && len(req.AttachmentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "A message needs content or at least one attachment",
+		})
+		return
+	}
+
+	if len(req.AttachmentIDs) > 0 {
+		var count int64
+		config.DB.Model(&models.Attachment{}).
+			Where("id IN ? AND room_id = ? AND uploader_id = ? AND message_id IS NULL", req.AttachmentIDs, roomID, currentUserID).
+			Count(&count)
+		if count != int64(len(req.AttachmentIDs)) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Attachments must be your own unsent uploads to this room",
+			})
+			return
+		}
+	}
+
+	if req.QuotedMessageID != nil {
+		if err := config.DB.First(&models.Message{}, "id = ? AND room_id = ?", *req.QuotedMessageID, roomID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Quoted message not found in this room",
+			})
+			return
+		}
+	}
+
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		if len(req.AttachmentIDs) > 0 {
+			// A concurrent send may have claimed an upload since it was checked
+			claim := tx.Model(&models.Attachment{}).
+				Where("id IN ? AND room_id = ? AND uploader_id = ? AND message_id IS NULL", req.AttachmentIDs, roomID, currentUserID).
+				Update("message_id", message.ID)
+			if claim.Error != nil {
+				return claim.Error
+			}
+			if claim.RowsAffected != int64(len(req.AttachmentIDs)) {
+				return errAttachmentsClaimed
+			}
+		}
+		if message.ParentID != nil {
+			err := tx.Unscoped().Model(&models.Message{}).Where("id = ?", *message.ParentID).Updates(map[string]any{
+				"reply_count":   gorm.Expr("reply_count + 1"),
+				"last_reply_at": message.CreatedAt,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Preload("Sender").Preload("QuotedMessage").Preload("Attachments").First(&message, "id = ?", message.ID).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueRoomEvent(tx, roomID, utils.Event{Type: "message.created", Data: message}); err != nil {
+			return err
+		}
+		if err := utils.EnqueueRoomWebhookEvent(tx, utils.WebhookMessageCreated, roomID, currentUserID, message); err != nil {
+			return err
+		}
+
+		// Each member gets at most one notification: mention, then thread reply, then new message
+		notified := map[uint]bool{currentUserID: true}
+		for _, mention := range message.Mentions {
+			notified[mention.UserID] = true
+		}
+		authUser := c.MustGet("authUser").(models.User)
+		if err := utils.NotifyMentions(tx, message, authUser.Name, nil); err != nil {
+			return err
+		}
+		if message.ParentID != nil {
+			if err := notifyThreadParticipants(tx, parent, message, authUser.Name, notified); err != nil {
+				return err
+			}
+		}
+		return notifyNewMessage(tx, message, authUser.Name, notified)
+	})
+	if errors.Is(err, errAttachmentsClaimed) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Attachments must be your own unsent uploads to this room",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to send message",
+		})
+		return
+	}
+
+	utils.Typing.Stop(roomID, currentUserID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message sent successfully",
+		"data":    message,
+	})
+}
+
+func ListMessages(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if !utils.IsRoomMember(roomID, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	cursorParams := utils.GetCursorParams(c)
+
+	// Deleted messages are kept in history as tombstones, thread replies are fetched per thread
+	query := config.DB.Unscoped().Model(&models.Message{}).Preload("Sender").Preload("QuotedMessage").Preload("Attachments").
+		Where("room_id = ? AND parent_id IS NULL", roomID)
+	if clearedAt := utils.HistoryClearedAt(roomID, currentUserID); clearedAt != nil {
+		query = query.Where("created_at > ?", *clearedAt)
+	}
+
+	sort := utils.KeysetSort{Column: "messages.created_at", IDColumn: "messages.id", Desc: true}
+	messages, paginationResult, err := utils.KeysetPaginate(query, cursorParams, sort, func(m models.Message) (any, any) {
+		return m.CreatedAt, m.ID
+	})
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch messages",
+		})
+		return
+	}
+
+	if err := utils.AttachReactionsToList(messages, currentUserID); err != nil {
+		log.Printf("Failed to load reactions: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Messages fetched successfully",
+		"data":       messages,
+		"pagination": paginationResult,
+	})
+}
+
+func EditMessage(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var req models.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ? AND sender_id = ? AND type = ?", messageID, currentUserID, models.MessageTypeUser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	if message.Content == req.Content {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Message unchanged",
+			"data":    message,
+		})
+		return
+	}
+
+	previouslyMentioned := map[uint]bool{}
+	for _, mention := range message.Mentions {
+		previouslyMentioned[mention.UserID] = true
+	}
+
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		edit := models.MessageEdit{
+			MessageID:       message.ID,
+			PreviousContent: message.Content,
+		}
+		if err := tx.Create(&edit).Error; err != nil {
+			return err
+		}
+
+		editedAt := utils.GetCurrentTimestamp()
+		message.Content = req.Content
+		message.EditedAt = &editedAt
+		message.Mentions = utils.MentionRoomMembers(message.RoomID, currentUserID, req.Content)
+		if err := tx.Model(&message).Select("content", "edited_at", "mentions").Updates(&message).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueRoomEvent(tx, message.RoomID, utils.Event{Type: "message.updated", Data: message}); err != nil {
+			return err
+		}
+		if err := utils.EnqueueRoomWebhookEvent(tx, utils.WebhookMessageUpdated, message.RoomID, currentUserID, message); err != nil {
+			return err
+		}
+
+		// Only users newly mentioned by the edit are notified
+		authUser := c.MustGet("authUser").(models.User)
+		return utils.NotifyMentions(tx, message, authUser.Name, previouslyMentioned)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to edit message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message edited successfully",
+		"data":    message,
+	})
+}
+
+func DeleteMessage(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ? AND sender_id = ? AND type = ?", messageID, currentUserID, models.MessageTypeUser).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&message).Error; err != nil {
+			return err
+		}
+		if message.ParentID != nil {
+			// The thread root may be a tombstone itself, its counters still show
+			lastReplyAt := tx.Model(&models.Message{}).Select("MAX(created_at)").Where("parent_id = ?", *message.ParentID)
+			err := tx.Unscoped().Model(&models.Message{}).Where("id = ?", *message.ParentID).Updates(map[string]any{
+				"reply_count":   gorm.Expr("GREATEST(reply_count - 1, 0)"),
+				"last_reply_at": gorm.Expr("(?)", lastReplyAt),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := utils.RefreshRoomLastMessage(tx, message.RoomID, message.ID); err != nil {
+			return err
+		}
+		if err := utils.EnqueueRoomEvent(tx, message.RoomID, utils.Event{
+			Type: "message.deleted",
+			Data: gin.H{"id": message.ID},
+		}); err != nil {
+			return err
+		}
+		return utils.EnqueueRoomWebhookEvent(tx, utils.WebhookMessageDeleted, message.RoomID, currentUserID, gin.H{"id": message.ID, "room_id": message.RoomID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to delete message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message deleted successfully",
+	})
+}
+
+func ListMessageEdits(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ?", messageID).Error; err != nil || !utils.IsRoomMember(message.RoomID, currentUserID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	var edits []models.MessageEdit
+	if err := config.DB.Where("message_id = ?", message.ID).Order("created_at ASC").Find(&edits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch message history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message history fetched successfully",
+		"data":    edits,
+	})
+}
+
+func GetThread(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var parent models.Message
+	if err := config.DB.Unscoped().Preload("Sender").First(&parent, "id = ? AND parent_id IS NULL", messageID).Error; err != nil || !utils.IsRoomMember(parent.RoomID, currentUserID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Thread not found",
+		})
+		return
+	}
+
+	paginationParams := utils.GetPaginationParams(c)
+
+	query := config.DB.Unscoped().Model(&models.Message{}).Preload("Sender").Preload("QuotedMessage").Preload("Attachments").
+		Where("parent_id = ?", parent.ID).Order("created_at ASC")
+	if clearedAt := utils.HistoryClearedAt(parent.RoomID, currentUserID); clearedAt != nil {
+		query = query.Where("created_at > ?", *clearedAt)
+	}
+	paginatedQuery, paginationResult := utils.Paginate(query, paginationParams)
+
+	var replies []models.Message
+	if err := paginatedQuery.Find(&replies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch thread",
+		})
+		return
+	}
+
+	if err := utils.AttachReactions([]*models.Message{&parent}, currentUserID); err != nil {
+		log.Printf("Failed to load reactions: %v", err)
+	}
+	if err := utils.AttachReactionsToList(replies, currentUserID); err != nil {
+		log.Printf("Failed to load reactions: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Thread fetched successfully",
+		"parent":     parent,
+		"data":       replies,
+		"pagination": paginationResult,
+	})
+}
+
+// notifyThreadParticipants tells the thread author and everyone who replied about a new reply in tx,
+// adding them to notified
+func notifyThreadParticipants(tx *gorm.DB, parent models.Message, reply models.Message, senderName string, notified map[uint]bool) error {
+	var participantIDs []uint
+	tx.Model(&models.Message{}).Where("parent_id = ?", parent.ID).Distinct().Pluck("sender_id", &participantIDs)
+	participantIDs = append(participantIDs, parent.SenderID)
+
+	var pending []uint
+	for _, userID := range participantIDs {
+		if !notified[userID] {
+			notified[userID] = true
+			pending = append(pending, userID)
+		}
+	}
+
+	for _, userID := range utils.RoomNotificationRecipients(reply.RoomID, pending, true) {
+		_, err := utils.CreateNotificationTx(tx, userID, utils.ThreadReplyPayload{
+			RoomID:     reply.RoomID,
+			MessageID:  reply.ID,
+			ThreadID:   parent.ID,
+			SenderID:   reply.SenderID,
+			SenderName: senderName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyNewMessage notifies members who are offline and not already notified in tx.
+// Online members see the message through their event stream instead.
+func notifyNewMessage(tx *gorm.DB, message models.Message, senderName string, notified map[uint]bool) error {
+	var pending []uint
+	for _, userID := range utils.GetRoomMemberIDs(message.RoomID) {
+		if !notified[userID] && utils.Hub.ConnectionCount(userID) == 0 {
+			pending = append(pending, userID)
+		}
+	}
+
+	for _, userID := range utils.RoomNotificationRecipients(message.RoomID, pending, false) {
+		_, err := utils.CreateNotificationTx(tx, userID, utils.NewMessagePayload{
+			RoomID:     message.RoomID,
+			MessageID:  message.ID,
+			SenderID:   message.SenderID,
+			SenderName: senderName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func SetTyping(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.TypingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if !utils.IsRoomMember(roomID, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	if req.Typing {
+		utils.Typing.Start(roomID, currentUserID)
+	} else {
+		utils.Typing.Stop(roomID, currentUserID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Typing status updated",
+	})
+}
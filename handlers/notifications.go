@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"log"
+	"errors"
 	"net/http"
+	"strings"
 
 	"gin-project/config"
 	"gin-project/models"
@@ -13,15 +14,33 @@ import (
 
 func ListNotifications(c *gin.Context) {
 	currentUserID := c.GetUint("userID")
-	paginationParams := utils.GetPaginationParams(c)
+	cursorParams := utils.GetCursorParams(c)
 
-	log.Println(currentUserID)
-
-	query := config.DB.Model(&models.Notification{}).Where("user_id = ?", currentUserID).Order("created_at DESC")
-	paginatedQuery, paginationResult := utils.Paginate(query, paginationParams)
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ? AND NOT hidden", currentUserID)
+	if c.Query("filter") == "mentions" {
+		query = query.Where("type = ?", utils.NotificationMention)
+	}
+	if types := c.Query("type"); types != "" {
+		query = query.Where("type IN ?", strings.Split(types, ","))
+	}
+	switch c.Query("read") {
+	case "true":
+		query = query.Where("read_at IS NOT NULL")
+	case "false":
+		query = query.Where("read_at IS NULL")
+	}
 
-	var notifications []models.Notification
-	if err := paginatedQuery.Find(&notifications).Error; err != nil {
+	sort := utils.KeysetSort{Column: "notifications.created_at", IDColumn: "notifications.id", Desc: true}
+	notifications, paginationResult, err := utils.KeysetPaginate(query, cursorParams, sort, func(n models.Notification) (any, any) {
+		return n.CreatedAt, n.ID
+	})
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Failed to fetch notifications",
 		})
@@ -39,19 +58,112 @@ func MarkNotificationAsRead(c *gin.Context) {
 	currentUserID := c.GetUint("userID")
 	notificationID := c.Param("notificationID")
 
-	var notification models.Notification
-	if err := config.DB.Where("id = ? AND user_id = ?", notificationID, currentUserID).First(&notification).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Notification not found",
+	// Only read_at is written, so an aggregation refreshing the same row isn't overwritten
+	result := config.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL AND NOT hidden", notificationID, currentUserID).
+		Update("read_at", utils.GetCurrentTimestamp())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to mark notification as read",
 		})
 		return
 	}
 
-	notification.Read = true
-	config.DB.Save(&notification)
+	if result.RowsAffected == 0 {
+		// Either it was read already, which is fine, or it isn't one of the user's visible notifications
+		var count int64
+		err := config.DB.Model(&models.Notification{}).
+			Where("id = ? AND user_id = ? AND NOT hidden", notificationID, currentUserID).
+			Count(&count).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Failed to mark notification as read",
+			})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Notification not found",
+			})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Notification marked as read",
 	})
+}
+
+func GetUnreadNotificationCount(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
 
+	count, err := utils.UnreadNotificationCount(currentUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to count unread notifications",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Unread count fetched successfully",
+		"data":    gin.H{"unread_count": count},
+	})
+}
+
+// MarkAllNotificationsAsRead marks every unread notification as read, or only those up to
+// up_to_id so notifications that arrived after the client rendered its list stay unread
+func MarkAllNotificationsAsRead(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.MarkNotificationsReadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			validationResponse := utils.FormatValidationErrors(err)
+			c.JSON(http.StatusBadRequest, validationResponse)
+			return
+		}
+	}
+
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL AND NOT hidden", currentUserID)
+	if req.UpToID != nil {
+		query = query.Where("id <= ?", *req.UpToID)
+	}
+
+	result := query.Update("read_at", utils.GetCurrentTimestamp())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to mark notifications as read",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifications marked as read",
+		"data":    gin.H{"updated": result.RowsAffected},
+	})
+}
+
+func DeleteNotifications(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.DeleteNotificationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	result := config.DB.Where("user_id = ? AND id IN ?", currentUserID, req.IDs).Delete(&models.Notification{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to delete notifications",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notifications deleted successfully",
+		"data":    gin.H{"deleted": result.RowsAffected},
+	})
 }
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errAlreadyPinned = errors.New("message is already pinned")
+	errTooManyPins   = errors.New("room has too many pinned messages")
+)
+
+func PinMessage(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ?", messageID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	room, member, err := utils.GetRoomMember(message.RoomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	if !member.CanModerate(room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only admins can pin messages in group rooms",
+		})
+		return
+	}
+
+	if message.Type == models.MessageTypeSystem {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "System messages cannot be pinned",
+		})
+		return
+	}
+
+	pin := models.PinnedMessage{
+		RoomID:     room.ID,
+		MessageID:  message.ID,
+		PinnedByID: currentUserID,
+	}
+	authUser := c.MustGet("authUser").(models.User)
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		// Locking the room serialises pins, so concurrent ones can't go over the limit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Room{}, "id = ?", room.ID).Error; err != nil {
+			return err
+		}
+		var pinCount int64
+		if err := tx.Model(&models.PinnedMessage{}).Where("room_id = ?", room.ID).Count(&pinCount).Error; err != nil {
+			return err
+		}
+		if pinCount >= models.MaxPinsPerRoom {
+			return errTooManyPins
+		}
+
+		if err := tx.Create(&pin).Error; err != nil {
+			if utils.IsUniqueViolation(err) {
+				return errAlreadyPinned
+			}
+			return err
+		}
+		pin.Message = &message
+
+		if err := utils.EnqueueRoomEvent(tx, room.ID, utils.Event{Type: "message.pinned", Data: pin}); err != nil {
+			return err
+		}
+		_, err := utils.CreateSystemMessageTx(tx, room.ID, currentUserID, utils.SystemMessagePinned,
+			map[string]any{"message_id": message.ID, "actor_id": currentUserID},
+			fmt.Sprintf("%s pinned a message", authUser.Name))
+		return err
+	})
+	if errors.Is(err, errAlreadyPinned) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Message is already pinned",
+		})
+		return
+	}
+	if errors.Is(err, errTooManyPins) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": fmt.Sprintf("A room can have at most %d pinned messages", models.MaxPinsPerRoom),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to pin message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Message pinned successfully",
+		"data":    pin,
+	})
+}
+
+func UnpinMessage(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var pin models.PinnedMessage
+	if err := config.DB.First(&pin, "message_id = ?", messageID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Pinned message not found",
+		})
+		return
+	}
+
+	room, member, err := utils.GetRoomMember(pin.RoomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Pinned message not found",
+		})
+		return
+	}
+
+	if !member.CanModerate(room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only admins can unpin messages in group rooms",
+		})
+		return
+	}
+
+	authUser := c.MustGet("authUser").(models.User)
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&pin).Error; err != nil {
+			return err
+		}
+
+		if err := utils.EnqueueRoomEvent(tx, room.ID, utils.Event{
+			Type: "message.unpinned",
+			Data: gin.H{"message_id": pin.MessageID},
+		}); err != nil {
+			return err
+		}
+		_, err := utils.CreateSystemMessageTx(tx, room.ID, currentUserID, utils.SystemMessageUnpinned,
+			map[string]any{"message_id": pin.MessageID, "actor_id": currentUserID},
+			fmt.Sprintf("%s unpinned a message", authUser.Name))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to unpin message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Message unpinned successfully",
+	})
+}
+
+func ListPinnedMessages(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	if !utils.IsRoomMember(roomID, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You are not a member of this room",
+		})
+		return
+	}
+
+	var pins []models.PinnedMessage
+	if err := config.DB.Preload("Message").Preload("Message.Sender").Preload("Message.Attachments").
+		Where("room_id = ?", roomID).Order("created_at DESC").Find(&pins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch pinned messages",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pinned messages fetched successfully",
+		"data":    pins,
+	})
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type quietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// notificationPreferencesResponse lists every type and channel, so clients don't need to know the defaults
+func notificationPreferencesResponse(user models.User) (gin.H, error) {
+	var stored []models.NotificationPreference
+	if err := config.DB.Where("user_id = ?", user.ID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(stored))
+	for _, preference := range stored {
+		enabled[preference.Type+"/"+preference.Channel] = preference.Enabled
+	}
+
+	var preferences []models.NotificationPreference
+	for _, notificationType := range utils.NotificationTypes() {
+		for _, channel := range utils.NotificationChannels {
+			preference := models.NotificationPreference{Type: notificationType, Channel: channel, Enabled: true}
+			if value, ok := enabled[notificationType+"/"+channel]; ok {
+				preference.Enabled = value
+			}
+			preferences = append(preferences, preference)
+		}
+	}
+
+	var quiet *quietHours
+	if user.QuietHoursStart != nil && user.QuietHoursEnd != nil {
+		quiet = &quietHours{Start: *user.QuietHoursStart, End: *user.QuietHoursEnd}
+	}
+
+	return gin.H{
+		"timezone":    user.Timezone,
+		"quiet_hours": quiet,
+		"preferences": preferences,
+	}, nil
+}
+
+func GetNotificationPreferences(c *gin.Context) {
+	authUser := c.MustGet("authUser").(models.User)
+
+	response, err := notificationPreferencesResponse(authUser)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notification preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    response,
+		"message": "Notification preferences fetched successfully",
+	})
+}
+
+func UpdateNotificationPreferences(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Unknown timezone %q", req.Timezone),
+		})
+		return
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(req.Preferences))
+	seen := make(map[[2]string]bool, len(req.Preferences))
+	for _, item := range req.Preferences {
+		if !utils.IsNotificationType(item.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Unknown notification type %q", item.Type),
+			})
+			return
+		}
+		key := [2]string{item.Type, item.Channel}
+		if seen[key] {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Preference for %q on %q is listed more than once", item.Type, item.Channel),
+			})
+			return
+		}
+		seen[key] = true
+		preferences = append(preferences, models.NotificationPreference{
+			UserID:  currentUserID,
+			Type:    item.Type,
+			Channel: item.Channel,
+			Enabled: *item.Enabled,
+		})
+	}
+
+	updates := map[string]any{"timezone": req.Timezone, "quiet_hours_start": nil, "quiet_hours_end": nil}
+	if req.QuietHours != nil {
+		updates["quiet_hours_start"] = req.QuietHours.Start
+		updates["quiet_hours_end"] = req.QuietHours.End
+	}
+
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", currentUserID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", currentUserID).Delete(&models.NotificationPreference{}).Error; err != nil {
+			return err
+		}
+		if len(preferences) > 0 {
+			if err := tx.Create(&preferences).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(&user, currentUserID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update notification preferences",
+		})
+		return
+	}
+
+	response, err := notificationPreferencesResponse(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notification preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    response,
+		"message": "Notification preferences updated successfully",
+	})
+}
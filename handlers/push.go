@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+func GetVAPIDPublicKey(c *gin.Context) {
+	if config.Push == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message": "Push notifications are not configured",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "VAPID public key fetched successfully",
+		"data":    gin.H{"public_key": config.Push.PublicKey()},
+	})
+}
+
+func ListPushSubscriptions(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var subscriptions []models.PushSubscription
+	if err := config.DB.Where("user_id = ?", currentUserID).Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch push subscriptions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Push subscriptions fetched successfully",
+		"data":    subscriptions,
+	})
+}
+
+// RegisterPushSubscription stores the device's subscription. Browsers reuse endpoints, so
+// registering a known endpoint again refreshes its keys and moves it to the current user.
+func RegisterPushSubscription(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.PushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	subscription := models.PushSubscription{
+		UserID:    currentUserID,
+		Endpoint:  req.Endpoint,
+		P256dh:    req.Keys.P256dh,
+		Auth:      req.Keys.Auth,
+		UserAgent: c.Request.UserAgent(),
+	}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "user_agent", "updated_at"}),
+	}).Create(&subscription).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to register push subscription",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Push subscription registered successfully",
+		"data":    subscription,
+	})
+}
+
+func DeletePushSubscription(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	subscriptionID := c.Param("subscriptionID")
+
+	result := config.DB.Where("id = ? AND user_id = ?", subscriptionID, currentUserID).Delete(&models.PushSubscription{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to remove push subscription",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Push subscription not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Push subscription removed successfully",
+	})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func AddReaction(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+
+	var req models.ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ?", messageID).Error; err != nil || !utils.IsRoomMember(message.RoomID, currentUserID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	reaction := models.MessageReaction{
+		MessageID: message.ID,
+		UserID:    currentUserID,
+		Emoji:     req.Emoji,
+	}
+
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		// Reacting twice with the same emoji is a no-op
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		return utils.EnqueueRoomEvent(tx, message.RoomID, utils.Event{
+			Type: "reaction.added",
+			Data: gin.H{"message_id": message.ID, "user_id": currentUserID, "emoji": req.Emoji},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to add reaction",
+		})
+		return
+	}
+
+	utils.AttachReactions([]*models.Message{&message}, currentUserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reaction added successfully",
+		"data":    message.Reactions,
+	})
+}
+
+func RemoveReaction(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	messageID := c.Param("messageID")
+	emoji := c.Param("emoji")
+
+	var message models.Message
+	if err := config.DB.First(&message, "id = ?", messageID).Error; err != nil || !utils.IsRoomMember(message.RoomID, currentUserID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Message not found",
+		})
+		return
+	}
+
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		result := tx.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, currentUserID, emoji).Delete(&models.MessageReaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return utils.EnqueueRoomEvent(tx, message.RoomID, utils.Event{
+			Type: "reaction.removed",
+			Data: gin.H{"message_id": message.ID, "user_id": currentUserID, "emoji": emoji},
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Reaction not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to remove reaction",
+		})
+		return
+	}
+
+	utils.AttachReactions([]*models.Message{&message}, currentUserID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Reaction removed successfully",
+		"data":    message.Reactions,
+	})
+}
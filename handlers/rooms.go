@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func CreateGroupRoom(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.CreateGroupRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	memberIDs := []uint{}
+	seen := map[uint]bool{currentUserID: true}
+	for _, userID := range req.MemberIDs {
+		if !seen[userID] {
+			seen[userID] = true
+			memberIDs = append(memberIDs, userID)
+		}
+	}
+
+	var count int64
+	config.DB.Model(&models.User{}).Where("id IN ?", memberIDs).Count(&count)
+	if count != int64(len(memberIDs)) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "One or more members were not found",
+		})
+		return
+	}
+
+	if !utils.CanAddBots(memberIDs, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only the owner of a bot can add it to a room",
+		})
+		return
+	}
+
+	room := models.Room{
+		Type:    models.RoomTypeGroup,
+		Name:    req.Name,
+		OwnerID: &currentUserID,
+	}
+
+	authUser := c.MustGet("authUser").(models.User)
+	err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&room).Error; err != nil {
+			return err
+		}
+
+		members := []models.RoomMember{{RoomID: room.ID, UserID: currentUserID, Role: models.RoleOwner}}
+		for _, userID := range memberIDs {
+			members = append(members, models.RoomMember{RoomID: room.ID, UserID: userID, Role: models.RoleMember})
+		}
+		if err := tx.Create(&members).Error; err != nil {
+			return err
+		}
+
+		_, err := utils.CreateSystemMessageTx(tx, room.ID, currentUserID, utils.SystemMessageRoomCreated,
+			map[string]any{
+				"room_type": models.RoomTypeGroup,
+				"name":      room.Name,
+				"actor_id":  currentUserID,
+				"user_ids":  memberIDs,
+			},
+			fmt.Sprintf("%s created the room \"%s\"", authUser.Name, room.Name))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create room",
+		})
+		return
+	}
+
+	config.DB.Preload("Members").First(&room, "id = ?", room.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Room created successfully",
+		"data":    room,
+	})
+}
+
+func UpdateMemberRole(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	targetUserID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "userID must be a valid number",
+		})
+		return
+	}
+
+	var req models.UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	room, member, err := utils.GetRoomMember(roomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+
+	if room.Type != models.RoomTypeGroup || member.Role != models.RoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only the owner of a group room can change roles",
+		})
+		return
+	}
+
+	var target models.User
+	config.DB.First(&target, targetUserID)
+	authUser := c.MustGet("authUser").(models.User)
+
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RoomMember{}).
+			Where("room_id = ? AND user_id = ? AND role <> ?", roomID, targetUserID, models.RoleOwner).
+			Update("role", req.Role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := utils.EnqueueRoomEvent(tx, roomID, utils.Event{
+			Type: "member.role_updated",
+			Data: gin.H{"user_id": targetUserID, "role": req.Role},
+		}); err != nil {
+			return err
+		}
+		_, err := utils.CreateSystemMessageTx(tx, roomID, currentUserID, utils.SystemMessageRoleChanged,
+			map[string]any{"actor_id": currentUserID, "user_id": targetUserID, "role": req.Role},
+			fmt.Sprintf("%s made %s %s", authUser.Name, target.Name, withArticle(req.Role)))
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Member not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to update role",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Role updated successfully",
+	})
+}
+
+func AddRoomMembers(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.AddRoomMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	room, member, err := utils.GetRoomMember(roomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+
+	if room.Type != models.RoomTypeGroup || !member.CanModerate(room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only admins of a group room can add members",
+		})
+		return
+	}
+
+	if !utils.CanAddBots(req.UserIDs, currentUserID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only the owner of a bot can add it to a room",
+		})
+		return
+	}
+
+	// Skip users who are already members
+	var newUsers []models.User
+	config.DB.Where("id IN ? AND id NOT IN (?)", req.UserIDs,
+		config.DB.Table("room_members").Select("user_id").Where("room_id = ?", roomID)).
+		Find(&newUsers)
+
+	if len(newUsers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "No new members to add",
+		})
+		return
+	}
+
+	var members []models.RoomMember
+	var addedIDs []uint
+	for _, user := range newUsers {
+		members = append(members, models.RoomMember{RoomID: roomID, UserID: user.ID, Role: models.RoleMember})
+		addedIDs = append(addedIDs, user.ID)
+	}
+	authUser := c.MustGet("authUser").(models.User)
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&members).Error; err != nil {
+			return err
+		}
+
+		if err := utils.EnqueueRoomEvent(tx, roomID, utils.Event{
+			Type: "member.added",
+			Data: gin.H{"user_ids": addedIDs},
+		}); err != nil {
+			return err
+		}
+		for _, user := range newUsers {
+			_, err := utils.CreateSystemMessageTx(tx, roomID, currentUserID, utils.SystemMessageMemberAdded,
+				map[string]any{"actor_id": currentUserID, "user_id": user.ID},
+				fmt.Sprintf("%s added %s", authUser.Name, user.Name))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to add members",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Members added successfully",
+		"data":    newUsers,
+	})
+}
+
+func RemoveRoomMember(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	targetUserID, err := strconv.ParseUint(c.Param("userID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "userID must be a valid number",
+		})
+		return
+	}
+
+	room, member, err := utils.GetRoomMember(roomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+
+	if room.Type != models.RoomTypeGroup || !member.CanModerate(room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only admins of a group room can remove members",
+		})
+		return
+	}
+
+	var target models.RoomMember
+	if err := config.DB.First(&target, "room_id = ? AND user_id = ?", roomID, targetUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Member not found",
+		})
+		return
+	}
+
+	// Admins can only remove regular members, the owner can remove anyone but themselves
+	if target.UserID == currentUserID || target.Role == models.RoleOwner ||
+		(target.Role == models.RoleAdmin && member.Role != models.RoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "You cannot remove this member",
+		})
+		return
+	}
+
+	var removedUser models.User
+	config.DB.First(&removedUser, targetUserID)
+	authUser := c.MustGet("authUser").(models.User)
+
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ? AND user_id = ?", roomID, targetUserID).Delete(&models.RoomMember{}).Error; err != nil {
+			return err
+		}
+
+		event := utils.Event{Type: "member.removed", RoomID: roomID, Data: gin.H{"user_id": targetUserID}}
+		if err := utils.EnqueueRoomEvent(tx, roomID, event); err != nil {
+			return err
+		}
+		if err := utils.EnqueueUserEvent(tx, target.UserID, event); err != nil {
+			return err
+		}
+		_, err := utils.CreateSystemMessageTx(tx, roomID, currentUserID, utils.SystemMessageMemberRemoved,
+			map[string]any{"actor_id": currentUserID, "user_id": targetUserID},
+			fmt.Sprintf("%s removed %s", authUser.Name, removedUser.Name))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to remove member",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed successfully",
+	})
+}
+
+func UpdateRoom(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	var req models.UpdateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	room, member, err := utils.GetRoomMember(roomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+
+	if room.Type != models.RoomTypeGroup || !member.CanModerate(room) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "Only admins of a group room can update it",
+		})
+		return
+	}
+
+	oldName := room.Name
+	updates := map[string]any{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.RequireApproval != nil {
+		updates["require_approval"] = *req.RequireApproval
+	}
+
+	if len(updates) > 0 {
+		authUser := c.MustGet("authUser").(models.User)
+		err := utils.OutboxTransaction(func(tx *gorm.DB) error {
+			if err := tx.Model(room).Updates(updates).Error; err != nil {
+				return err
+			}
+			if req.Name == nil || *req.Name == oldName {
+				return nil
+			}
+
+			_, err := utils.CreateSystemMessageTx(tx, roomID, currentUserID, utils.SystemMessageRoomRenamed,
+				map[string]any{"actor_id": currentUserID, "old_name": oldName, "new_name": *req.Name},
+				fmt.Sprintf("%s renamed the room to \"%s\"", authUser.Name, *req.Name))
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Failed to update room",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Room updated successfully",
+		"data":    room,
+	})
+}
+
+func LeaveRoom(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	room, _, err := utils.GetRoomMember(roomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+
+	if room.Type != models.RoomTypeGroup {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Direct conversations cannot be left, delete the conversation instead",
+		})
+		return
+	}
+
+	authUser := c.MustGet("authUser").(models.User)
+	var deletedAttachments []models.Attachment
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		// Locking the room serialises leaves, so two members leaving at once can't both hand
+		// ownership to each other or leave the room without an owner. Joins take a share lock,
+		// so nobody can join a room that is being deleted.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Room{}, "id = ?", roomID).Error; err != nil {
+			return err
+		}
+		var member models.RoomMember
+		if err := tx.First(&member, "room_id = ? AND user_id = ?", roomID, currentUserID).Error; err != nil {
+			return err
+		}
+
+		var newOwner *models.RoomMember
+		if member.Role == models.RoleOwner {
+			// No one to hand over to means the room is about to be empty
+			var err error
+			newOwner, err = utils.PickNextOwner(tx, roomID, currentUserID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		if err := tx.Where("room_id = ? AND user_id = ?", roomID, currentUserID).Delete(&models.RoomMember{}).Error; err != nil {
+			return err
+		}
+		if err := utils.EnqueueUserEvent(tx, currentUserID, utils.Event{Type: "member.left", RoomID: roomID, Data: gin.H{"user_id": currentUserID}}); err != nil {
+			return err
+		}
+
+		var remaining int64
+		if err := tx.Model(&models.RoomMember{}).Where("room_id = ?", roomID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining == 0 {
+			var err error
+			deletedAttachments, err = utils.DeleteRoomTx(tx, roomID)
+			return err
+		}
+
+		if err := utils.EnqueueRoomEvent(tx, roomID, utils.Event{Type: "member.left", Data: gin.H{"user_id": currentUserID}}); err != nil {
+			return err
+		}
+		_, err := utils.CreateSystemMessageTx(tx, roomID, currentUserID, utils.SystemMessageMemberLeft,
+			map[string]any{"actor_id": currentUserID, "user_id": currentUserID},
+			fmt.Sprintf("%s left the room", authUser.Name))
+		if err != nil || newOwner == nil {
+			return err
+		}
+
+		if err := tx.Model(&models.RoomMember{}).Where("room_id = ? AND user_id = ?", roomID, newOwner.UserID).Update("role", models.RoleOwner).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(room).Update("owner_id", newOwner.UserID).Error; err != nil {
+			return err
+		}
+
+		var ownerUser models.User
+		tx.First(&ownerUser, newOwner.UserID)
+		_, err = utils.CreateSystemMessageTx(tx, roomID, currentUserID, utils.SystemMessageOwnerChanged,
+			map[string]any{"actor_id": currentUserID, "user_id": newOwner.UserID},
+			fmt.Sprintf("%s is now the owner", ownerUser.Name))
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to leave room",
+		})
+		return
+	}
+
+	utils.DeleteAttachmentFiles(c.Request.Context(), deletedAttachments)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "You left the room",
+	})
+}
+
+// ClearConversation deletes a direct conversation for the current user only.
+// The other member keeps the full history.
+func ClearConversation(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	roomID := c.Param("roomID")
+
+	room, _, err := utils.GetRoomMember(roomID, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Room not found",
+		})
+		return
+	}
+
+	if room.Type != models.RoomTypeDirect {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Only direct conversations can be deleted, leave group rooms instead",
+		})
+		return
+	}
+
+	clearedAt := utils.GetCurrentTimestamp()
+	err = utils.OutboxTransaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RoomMember{}).Where("room_id = ? AND user_id = ?", roomID, currentUserID).Updates(map[string]any{
+			"cleared_at":           clearedAt,
+			"last_read_at":         clearedAt,
+			"last_read_message_id": nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return utils.EnqueueUserEvent(tx, currentUserID, utils.Event{
+			Type:   "room.cleared",
+			RoomID: roomID,
+			Data:   gin.H{"cleared_at": clearedAt},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to delete conversation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Conversation deleted",
+	})
+}
+
+func withArticle(role string) string {
+	if role == models.RoleAdmin {
+		return "an admin"
+	}
+	return "a member"
+}
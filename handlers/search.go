@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseSearchTime accepts either a full RFC3339 timestamp or a plain date
+func parseSearchTime(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.DateOnly, value)
+	return t, true, err
+}
+
+func SearchMessages(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	searchText := c.Query("q")
+	if searchText == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Search query q is required",
+		})
+		return
+	}
+
+	tsQuery := "websearch_to_tsquery('" + utils.SearchLanguage + "', ?)"
+	query := config.DB.Model(&models.Message{}).
+		Where("search_vector @@ "+tsQuery, searchText).
+		Where("type = ?", models.MessageTypeUser).
+		Where(`EXISTS (SELECT 1 FROM room_members AS rm WHERE rm.room_id = messages.room_id AND rm.user_id = ?
+			AND (rm.cleared_at IS NULL OR messages.created_at > rm.cleared_at))`, currentUserID)
+
+	if roomID := c.Query("room_id"); roomID != "" {
+		query = query.Where("room_id = ?", roomID)
+	}
+
+	if senderIDStr := c.Query("sender_id"); senderIDStr != "" {
+		senderID, err := strconv.ParseUint(senderIDStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "sender_id must be a valid number",
+			})
+			return
+		}
+		query = query.Where("sender_id = ?", senderID)
+	}
+
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, _, err := parseSearchTime(fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "from must be a date (YYYY-MM-DD) or RFC3339 timestamp",
+			})
+			return
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+
+	if toStr := c.Query("to"); toStr != "" {
+		to, dateOnly, err := parseSearchTime(toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "to must be a date (YYYY-MM-DD) or RFC3339 timestamp",
+			})
+			return
+		}
+		// A plain date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+			query = query.Where("created_at < ?", to)
+		} else {
+			query = query.Where("created_at <= ?", to)
+		}
+	}
+
+	paginationParams := utils.GetPaginationParams(c)
+	paginatedQuery, paginationResult := utils.Paginate(query, paginationParams)
+
+	var matches []struct {
+		ID      string
+		Rank    float64
+		Snippet string
+	}
+	err := paginatedQuery.
+		Select("id, ts_rank(search_vector, "+tsQuery+") AS rank, ts_headline('"+utils.SearchLanguage+"', content, "+tsQuery+", ?) AS snippet", searchText, searchText, utils.SearchHeadlineOptions).
+		Order("rank DESC, created_at DESC").
+		Scan(&matches).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to search messages",
+		})
+		return
+	}
+
+	results := make([]models.MessageSearchResult, 0, len(matches))
+	if len(matches) > 0 {
+		messageIDs := make([]string, 0, len(matches))
+		for _, match := range matches {
+			messageIDs = append(messageIDs, match.ID)
+		}
+
+		var messages []models.Message
+		config.DB.Preload("Sender").Preload("Attachments").Where("id IN ?", messageIDs).Find(&messages)
+		utils.AttachReactionsToList(messages, currentUserID)
+
+		byID := make(map[string]*models.Message, len(messages))
+		for i := range messages {
+			byID[messages[i].ID] = &messages[i]
+		}
+
+		for _, match := range matches {
+			if message, ok := byID[match.ID]; ok {
+				results = append(results, models.MessageSearchResult{
+					Message: message,
+					Rank:    match.Rank,
+					Snippet: utils.HighlightSnippet(match.Snippet),
+				})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Search completed successfully",
+		"data":       results,
+		"pagination": paginationResult,
+	})
+}
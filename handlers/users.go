@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"gin-project/config"
 	"gin-project/models"
+	"gin-project/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,8 +52,182 @@ func GetProfile(c *gin.Context) {
 
 	user.Password = ""
 
+	// Settings and last seen are only shown to the user themselves, others see last seen through presence
+	var data any = user
+	if user.ID == c.GetUint("userID") {
+		data = models.NewOwnProfile(user)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":    user,
+		"data":    data,
 		"message": "Profile fetched successfully",
 	})
 }
+
+func GetPresence(c *gin.Context) {
+	userIDsParam := c.Query("user_ids")
+	if userIDsParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "user_ids parameter is required",
+		})
+		return
+	}
+
+	var userIDs []uint
+	for _, idStr := range strings.Split(userIDsParam, ",") {
+		userID64, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "user_ids must be a comma separated list of numbers",
+			})
+			return
+		}
+		userIDs = append(userIDs, uint(userID64))
+	}
+
+	if len(userIDs) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "At most 100 user_ids can be queried at once",
+		})
+		return
+	}
+
+	presences, err := utils.GetPresence(c.GetUint("userID"), userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch presence",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    presences,
+		"message": "Presence fetched successfully",
+	})
+}
+
+func UpdatePrivacy(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.UpdatePrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", currentUserID).Update("hide_last_seen", *req.HideLastSeen).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update privacy settings",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    gin.H{"hide_last_seen": *req.HideLastSeen},
+		"message": "Privacy settings updated successfully",
+	})
+}
+
+func UpdateLocale(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.UpdateLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if !utils.IsSupportedLocale(req.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Unsupported locale %q", req.Locale),
+		})
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", currentUserID).Update("locale", req.Locale).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update locale",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    gin.H{"locale": req.Locale},
+		"message": "Locale updated successfully",
+	})
+}
+
+func UpdateEmailNotifications(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var req models.UpdateEmailNotificationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", currentUserID).Update("email_notifications", req.Frequency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update email notifications",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    gin.H{"email_notifications": req.Frequency},
+		"message": "Email notifications updated successfully",
+	})
+}
+
+// ConfirmUnsubscribeEmail shows the page behind the signed link in digests. Following the link
+// only asks for confirmation, so link scanners and prefetching can't unsubscribe anyone.
+func ConfirmUnsubscribeEmail(c *gin.Context) {
+	token := c.Query("token")
+	if _, ok := utils.ParseUnsubscribeToken(token); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid unsubscribe link",
+		})
+		return
+	}
+
+	var page bytes.Buffer
+	if err := utils.RenderUnsubscribePage(&page, token, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to render page",
+		})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// UnsubscribeEmail turns email notifications off without a login. It answers the confirmation
+// form and one-click unsubscribe from mail clients (RFC 8058).
+func UnsubscribeEmail(c *gin.Context) {
+	token := c.Query("token")
+	userID, ok := utils.ParseUnsubscribeToken(token)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid unsubscribe link",
+		})
+		return
+	}
+
+	if err := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("email_notifications", models.EmailOff).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unsubscribe",
+		})
+		return
+	}
+
+	var page bytes.Buffer
+	if err := utils.RenderUnsubscribePage(&page, token, true); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "You will no longer receive notification emails",
+		})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
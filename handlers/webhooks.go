@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func ListWebhookEvents(c *gin.Context) {
+	authUser := c.MustGet("authUser").(models.User)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook events fetched successfully",
+		"data":    utils.WebhookEventsFor(authUser),
+	})
+}
+
+func ListWebhooks(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+
+	var webhooks []models.Webhook
+	if err := config.DB.Where("user_id = ?", currentUserID).Order("created_at DESC").Find(&webhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch webhooks",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhooks fetched successfully",
+		"data":    webhooks,
+	})
+}
+
+// CreateWebhook registers an endpoint. The signing secret is only returned here.
+func CreateWebhook(c *gin.Context) {
+	authUser := c.MustGet("authUser").(models.User)
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	if event := unavailableWebhookEvent(authUser, req.Events); event != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": fmt.Sprintf("Unknown webhook event: %s", event),
+		})
+		return
+	}
+
+	if err := utils.ValidateWebhookURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Webhook URL must be a public http or https address",
+		})
+		return
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to generate webhook secret",
+		})
+		return
+	}
+
+	webhook := models.Webhook{
+		UserID:      authUser.ID,
+		URL:         req.URL,
+		Description: req.Description,
+		Secret:      "whsec_" + secret,
+		Events:      req.Events,
+		Active:      true,
+	}
+	if err := config.DB.Create(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully",
+		"data":    webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+func UpdateWebhook(c *gin.Context) {
+	authUser := c.MustGet("authUser").(models.User)
+	webhookID := c.Param("webhookID")
+
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		validationResponse := utils.FormatValidationErrors(err)
+		c.JSON(http.StatusBadRequest, validationResponse)
+		return
+	}
+
+	var webhook models.Webhook
+	if err := config.DB.First(&webhook, "id = ? AND user_id = ?", webhookID, authUser.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook not found",
+		})
+		return
+	}
+
+	if req.Events != nil {
+		if event := unavailableWebhookEvent(authUser, req.Events); event != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": fmt.Sprintf("Unknown webhook event: %s", event),
+			})
+			return
+		}
+		webhook.Events = req.Events
+	}
+	if req.URL != nil {
+		if err := utils.ValidateWebhookURL(*req.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Webhook URL must be a public http or https address",
+			})
+			return
+		}
+		webhook.URL = *req.URL
+	}
+	if req.Description != nil {
+		webhook.Description = *req.Description
+	}
+	if req.Active != nil {
+		// Turning a disabled webhook back on gives it a fresh start
+		if *req.Active && !webhook.Active {
+			webhook.ConsecutiveFailures = 0
+			webhook.DisabledAt = nil
+		}
+		webhook.Active = *req.Active
+	}
+
+	if err := config.DB.Save(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to update webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook updated successfully",
+		"data":    webhook,
+	})
+}
+
+func DeleteWebhook(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	webhookID := c.Param("webhookID")
+
+	var webhook models.Webhook
+	if err := config.DB.First(&webhook, "id = ? AND user_id = ?", webhookID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook not found",
+		})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&webhook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to delete webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// ListWebhookDeliveries lists the delivery attempts of a webhook, newest first
+func ListWebhookDeliveries(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	webhookID := c.Param("webhookID")
+	cursorParams := utils.GetCursorParams(c)
+
+	var webhook models.Webhook
+	if err := config.DB.First(&webhook, "id = ? AND user_id = ?", webhookID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook not found",
+		})
+		return
+	}
+
+	query := config.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)
+	switch c.Query("success") {
+	case "true":
+		query = query.Where("success")
+	case "false":
+		query = query.Where("NOT success")
+	}
+
+	sort := utils.KeysetSort{Column: "webhook_deliveries.created_at", IDColumn: "webhook_deliveries.id", Desc: true}
+	deliveries, paginationResult, err := utils.KeysetPaginate(query, cursorParams, sort, func(d models.WebhookDelivery) (any, any) {
+		return d.CreatedAt, d.ID
+	})
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to fetch webhook deliveries",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Webhook deliveries fetched successfully",
+		"data":       deliveries,
+		"pagination": paginationResult,
+	})
+}
+
+// RedeliverWebhookDelivery sends the event of a logged delivery again
+func RedeliverWebhookDelivery(c *gin.Context) {
+	currentUserID := c.GetUint("userID")
+	webhookID := c.Param("webhookID")
+	deliveryID := c.Param("deliveryID")
+
+	var webhook models.Webhook
+	if err := config.DB.First(&webhook, "id = ? AND user_id = ?", webhookID, currentUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook not found",
+		})
+		return
+	}
+
+	var delivery models.WebhookDelivery
+	if err := config.DB.First(&delivery, "id = ? AND webhook_id = ?", deliveryID, webhook.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Webhook delivery not found",
+		})
+		return
+	}
+
+	if !webhook.Active {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Webhook is disabled, enable it before redelivering",
+		})
+		return
+	}
+
+	if err := utils.RedeliverWebhook(delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to redeliver webhook event",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Webhook event queued for redelivery",
+	})
+}
+
+// unavailableWebhookEvent returns the first event the user can't subscribe to, if any
+func unavailableWebhookEvent(user models.User, events []string) string {
+	for _, event := range events {
+		if !utils.CanSubscribeWebhook(user, event) {
+			return event
+		}
+	}
+	return ""
+}
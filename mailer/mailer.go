@@ -0,0 +1,15 @@
+package mailer
+
+// Message is an email with a plain text body and an optional HTML alternative
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(msg Message) error
+}
@@ -0,0 +1,195 @@
+package mailer
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// capturedMail is what the fake SMTP server received for one message
+type capturedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer runs a minimal SMTP server that accepts a single message and hands it over on the channel
+func startSMTPServer(t *testing.T) (string, <-chan capturedMail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan capturedMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var captured capturedMail
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.TrimRight(line, "\r\n")
+			upper := strings.ToUpper(command)
+
+			switch {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				captured.from = strings.Trim(command[len("MAIL FROM:"):], "<> ")
+				reply("250 OK")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				captured.to = append(captured.to, strings.Trim(command[len("RCPT TO:"):], "<> "))
+				reply("250 OK")
+			case upper == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(line, "."))
+				}
+				captured.data = data.String()
+				reply("250 OK")
+			case upper == "QUIT":
+				reply("221 Bye")
+				received <- captured
+				return
+			default:
+				reply("502 Command not implemented")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), received
+}
+
+func newTestMailer(t *testing.T, addr string) *SMTPMailer {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSMTPMailer(host, port, "", "", "Chat <noreply@example.com>")
+}
+
+func TestSMTPMailerSendsMultipartMessage(t *testing.T) {
+	addr, received := startSMTPServer(t)
+
+	err := newTestMailer(t, addr).Send(Message{
+		To:      "ann@example.com",
+		Subject: "Vous avez 2 notifications",
+		Text:    "Hi Ann, you have 2 new notifications.",
+		HTML:    "<p>Hi Ann, you have <b>2</b> new notifications.</p>",
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<https://chat.example.com/email/unsubscribe?token=1.abc>",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	captured := <-received
+	if captured.from != "noreply@example.com" {
+		t.Errorf("MAIL FROM %q, want noreply@example.com", captured.from)
+	}
+	if len(captured.to) != 1 || captured.to[0] != "ann@example.com" {
+		t.Errorf("RCPT TO %v, want [ann@example.com]", captured.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(captured.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Vous avez 2 notifications" {
+		t.Errorf("Subject %q (%v)", subject, err)
+	}
+	if got := msg.Header.Get("To"); got != "ann@example.com" {
+		t.Errorf("To %q", got)
+	}
+	if got := msg.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("List-Unsubscribe-Post %q", got)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<https://chat.example.com/email/unsubscribe?token=1.abc>" {
+		t.Errorf("List-Unsubscribe %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatalf("decode part: %v", err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+
+	if parts["text/plain"] != "Hi Ann, you have 2 new notifications." {
+		t.Errorf("text part %q", parts["text/plain"])
+	}
+	if parts["text/html"] != "<p>Hi Ann, you have <b>2</b> new notifications.</p>" {
+		t.Errorf("html part %q", parts["text/html"])
+	}
+}
+
+func TestSMTPMailerSendsPlainTextMessage(t *testing.T) {
+	addr, received := startSMTPServer(t)
+
+	text := "A line long enough to need a soft break in quoted-printable, since those lines stop at 76 characters = ok"
+	if err := newTestMailer(t, addr).Send(Message{To: "bob@example.com", Subject: "Hello", Text: text}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader((<-received).data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	if got := msg.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type %q", got)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	// The SMTP client ends the data with a line break of its own
+	if strings.TrimSuffix(string(body), "\r\n") != text {
+		t.Errorf("body %q, want %q", body, text)
+	}
+}
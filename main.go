@@ -3,10 +3,12 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"gin-project/config"
 	"gin-project/models"
 	"gin-project/routes"
+	"gin-project/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -18,15 +20,40 @@ func main() {
 	}
 
 	config.ConnectDB()
+	config.ConnectStorage()
+	config.ConnectMailer()
+	config.ConnectPush()
 
 	// Auto-migrate database tables
 	db := config.GetDB()
-	err := db.AutoMigrate(&models.User{}, &models.Room{}, &models.ChatRequest{}, &models.Notification{}, &models.Message{})
+	if err := db.SetupJoinTable(&models.Room{}, "Members", &models.RoomMember{}); err != nil {
+		log.Fatal("Failed to set up room members table:", err)
+	}
+
+	err := db.AutoMigrate(&models.User{}, &models.Room{}, &models.ChatRequest{}, &models.Notification{}, &models.Message{}, &models.MessageEdit{}, &models.MessageReaction{}, &models.RoomMember{}, &models.Attachment{}, &models.PinnedMessage{}, &models.RoomInvite{}, &models.JoinRequest{}, &models.PushSubscription{}, &models.NotificationPreference{}, &models.OutboxEntry{}, &models.Webhook{}, &models.WebhookDelivery{}, &models.BotToken{})
 
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	if err := utils.SetupMessageSearch(db); err != nil {
+		log.Fatal("Failed to set up message search:", err)
+	}
+
+	if err := utils.BackfillRoomActivity(db); err != nil {
+		log.Fatal("Failed to backfill room activity:", err)
+	}
+
+	if err := utils.MigrateNotificationReadAt(db); err != nil {
+		log.Fatal("Failed to migrate notification read state:", err)
+	}
+
+	utils.StartEventRelay()
+	utils.StartOutboxDispatcher(time.Second)
+	utils.StartChatRequestExpiry(time.Hour)
+	utils.StartEmailDigests(time.Minute)
+	utils.StartNotificationRetention(time.Hour)
+
 	// Initialize Gin router
 	router := gin.Default()
 
@@ -7,6 +7,7 @@ import (
 
 	"gin-project/config"
 	"gin-project/models"
+	"gin-project/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -90,3 +91,57 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminMiddleware only lets admins through, it runs after AuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !c.MustGet("authUser").(models.User).IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Admin access required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// BotAuthMiddleware authenticates bots by the "Authorization: Bot <token>" header and sets the
+// same context keys as AuthMiddleware, so bots can use the regular chat handlers
+func BotAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bot ")
+		if !ok || token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Bot token required",
+			})
+			c.Abort()
+			return
+		}
+
+		var botToken models.BotToken
+		if err := config.DB.Where("token_hash = ?", utils.HashBotToken(token)).First(&botToken).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid bot token",
+			})
+			c.Abort()
+			return
+		}
+
+		var bot models.User
+		if err := config.DB.Where("id = ? AND is_bot", botToken.BotID).First(&bot).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Bot not found",
+			})
+			c.Abort()
+			return
+		}
+
+		config.DB.Model(&botToken).Update("last_used_at", utils.GetCurrentTimestamp())
+
+		c.Set("userID", bot.ID)
+		c.Set("email", bot.Email)
+		c.Set("authUser", bot)
+		c.Next()
+	}
+}
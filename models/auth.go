@@ -13,6 +13,6 @@ type RegisterRequest struct {
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token string     `json:"token"`
+	User  OwnProfile `json:"user"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// BotToken authenticates a bot against the bot API. Only a hash of the token is stored, it is
+// shown once when the bot is created or the token is regenerated.
+type BotToken struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time  `json:"created_at"`
+	BotID      uint       `json:"bot_id" gorm:"not null;uniqueIndex"`
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Bot is a bot account as shown to its owner
+type Bot struct {
+	User
+	WebhookURL *string `json:"webhook_url"`
+}
+
+type CreateBotRequest struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	WebhookURL string `json:"webhook_url" binding:"omitempty,url"`
+}
+
+// UpdateBotRequest changes the fields that are set, an empty webhook_url stops message delivery
+type UpdateBotRequest struct {
+	Name       *string `json:"name" binding:"omitempty,min=1,max=100"`
+	WebhookURL *string `json:"webhook_url" binding:"omitempty,url"`
+}
@@ -1,18 +1,141 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Room types
+const (
+	RoomTypeDirect = "direct"
+	RoomTypeGroup  = "group"
+)
+
+// Member roles, only meaningful in group rooms
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
 type Room struct {
-	ID        string         `json:"id" gorm:"primarykey"`
+	ID        string         `json:"id" gorm:"primarykey;index:idx_rooms_last_activity,priority:2"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	Type      string         `json:"type" gorm:"size:20;not null;default:direct"`
+	Name      string         `json:"name" gorm:"size:100"`
+	OwnerID   *uint          `json:"owner_id"`
 	Members   []User         `json:"members" gorm:"many2many:room_members"`
+
+	// Denormalized from messages so room lists can be sorted without scanning them.
+	// idx_rooms_last_activity serves the (last_activity_at, id) keyset of room lists in either direction.
+	LastMessageID  *string   `json:"last_message_id"`
+	LastActivityAt time.Time `json:"last_activity_at" gorm:"index:idx_rooms_last_activity,priority:1"`
+
+	// Invite joins become join requests that admins approve
+	RequireApproval bool `json:"require_approval" gorm:"not null;default:false"`
+}
+
+// RoomMember is the room_members join table with per-member state
+type RoomMember struct {
+	RoomID            string     `json:"room_id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"primaryKey;index"`
+	Role              string     `json:"role" gorm:"size:20;not null;default:member"`
+	JoinedAt          time.Time  `json:"joined_at" gorm:"autoCreateTime"`
+	ClearedAt         *time.Time `json:"cleared_at"`
+	LastReadMessageID *string    `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at"`
+	MutedUntil        *time.Time `json:"muted_until"`
+	Archived          bool       `json:"archived" gorm:"not null;default:false"`
+	NotificationLevel string     `json:"notification_level" gorm:"size:20;not null;default:all"`
+}
+
+// Notification levels for a room
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+// IsMuted reports whether notifications from the room are currently muted for the member
+func (m *RoomMember) IsMuted(now time.Time) bool {
+	return m.MutedUntil != nil && m.MutedUntil.After(now)
+}
+
+// CanModerate reports whether the member may manage the room (pins, members...).
+// Every member of a direct room can, in group rooms only owners and admins.
+func (m *RoomMember) CanModerate(room *Room) bool {
+	return room.Type != RoomTypeGroup || m.Role == RoleOwner || m.Role == RoleAdmin
+}
+
+type CreateGroupRoomRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	MemberIDs []uint `json:"member_ids" binding:"max=255"`
+}
+
+type AddRoomMembersRequest struct {
+	UserIDs []uint `json:"user_ids" binding:"required,min=1,max=100"`
+}
+
+// UpdateRoomSettingsRequest changes only the fields that are present
+type UpdateRoomSettingsRequest struct {
+	MutedUntil        *time.Time `json:"muted_until"`
+	Unmute            bool       `json:"unmute"`
+	Archived          *bool      `json:"archived"`
+	NotificationLevel *string    `json:"notification_level" binding:"omitempty,oneof=all mentions none"`
+}
+
+// UpdateRoomRequest changes only the fields that are present
+type UpdateRoomRequest struct {
+	Name            *string `json:"name" binding:"omitempty,min=1,max=100"`
+	RequireApproval *bool   `json:"require_approval"`
+}
+
+// RoomInvite is a shareable link that lets people join a group room
+type RoomInvite struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RoomID      string     `json:"room_id" gorm:"not null;index"`
+	CreatedByID uint       `json:"created_by_id" gorm:"not null"`
+	Token       string     `json:"token" gorm:"size:64;not null;uniqueIndex"`
+	MaxUses     *int       `json:"max_uses"`
+	Uses        int        `json:"uses" gorm:"not null;default:0"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+type CreateInviteRequest struct {
+	MaxUses   *int       `json:"max_uses" binding:"omitempty,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// JoinRequest is a pending invite join in a room that requires admin approval
+type JoinRequest struct {
+	ID        string         `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	RoomID    string         `json:"room_id" gorm:"not null;index"`
+	UserID    uint           `json:"user_id" gorm:"not null;index"`
+	User      User           `json:"user" gorm:"foreignKey:UserID"`
+	InviteID  uint           `json:"invite_id" gorm:"not null"`
+	Status    string         `json:"status" gorm:"type:varchar(20);default:'pending'"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (jr *JoinRequest) BeforeCreate(tx *gorm.DB) error {
+	if jr.ID == "" {
+		jr.ID = uuid.New().String()
+	}
+	return nil
+}
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin member"`
 }
 
 // BeforeCreate will set a UUID rather than numeric ID
@@ -20,6 +143,9 @@ func (r *Room) BeforeCreate(tx *gorm.DB) error {
 	if r.ID == "" {
 		r.ID = uuid.New().String()
 	}
+	if r.LastActivityAt.IsZero() {
+		r.LastActivityAt = time.Now().UTC()
+	}
 	return nil
 }
 
@@ -60,16 +186,173 @@ type AcceptOrRejectChatRequest struct {
 
 type Message struct {
 	ID        string         `json:"id" gorm:"primarykey"`
-	CreatedAt time.Time      `json:"created_at"`
+	CreatedAt time.Time      `json:"created_at" gorm:"index:idx_messages_room_created,priority:2"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
-	RoomID    string         `json:"room_id" gorm:"not null;index"`
-	Room      Room           `json:"room" gorm:"foreignKey:RoomID"`
+	RoomID    string         `json:"room_id" gorm:"not null;index;index:idx_messages_room_created,priority:1"`
+	Room      *Room          `json:"room,omitempty" gorm:"foreignKey:RoomID"`
 	SenderID  uint           `json:"sender_id" gorm:"not null"`
+	Sender    *User          `json:"sender,omitempty" gorm:"foreignKey:SenderID"`
 	Content   string         `json:"content" gorm:"type:text;not null"`
+	EditedAt  *time.Time     `json:"edited_at"`
+	Deleted   bool           `json:"deleted" gorm:"-"`
+
+	// System messages describe room events, Payload holds the structured details
+	Type    string          `json:"type" gorm:"size:20;not null;default:user"`
+	Payload json.RawMessage `json:"payload,omitempty" gorm:"type:jsonb"`
+
+	// Threads are one level deep: replies point at the top-level message
+	ParentID        *string    `json:"parent_id" gorm:"index"`
+	ReplyCount      int        `json:"reply_count" gorm:"default:0"`
+	LastReplyAt     *time.Time `json:"last_reply_at"`
+	QuotedMessageID *string    `json:"quoted_message_id"`
+	QuotedMessage   *Message   `json:"quoted_message,omitempty" gorm:"foreignKey:QuotedMessageID"`
+
+	Mentions    []MessageMention  `json:"mentions" gorm:"type:jsonb;serializer:json"`
+	Reactions   []ReactionSummary `json:"reactions" gorm:"-"`
+	Attachments []Attachment      `json:"attachments" gorm:"foreignKey:MessageID"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (m *Message) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AfterCreate keeps the room's last message and activity time current, in the same transaction
+func (m *Message) AfterCreate(tx *gorm.DB) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Model(&Room{}).Where("id = ?", m.RoomID).
+		UpdateColumns(map[string]any{
+			"last_message_id":  m.ID,
+			"last_activity_at": m.CreatedAt,
+		}).Error
+}
+
+// AfterFind renders soft deleted messages as tombstones
+func (m *Message) AfterFind(tx *gorm.DB) error {
+	if m.DeletedAt.Valid {
+		m.Deleted = true
+		m.Content = ""
+	}
+	return nil
+}
+
+// Message types
+const (
+	MessageTypeUser   = "user"
+	MessageTypeSystem = "system"
+)
+
+// MessageMention marks where a room member is @mentioned in a message.
+// Offset and Length count characters (runes), not bytes.
+type MessageMention struct {
+	UserID uint `json:"user_id"`
+	Offset int  `json:"offset"`
+	Length int  `json:"length"`
+}
+
+// MessageEdit keeps the content a message had before each edit
+type MessageEdit struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	CreatedAt       time.Time `json:"created_at"`
+	MessageID       string    `json:"message_id" gorm:"not null;index"`
+	PreviousContent string    `json:"previous_content" gorm:"type:text;not null"`
+}
+
+// MessageReaction is one user's emoji on a message
+type MessageReaction struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	MessageID string    `json:"message_id" gorm:"not null;uniqueIndex:idx_message_user_emoji"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_message_user_emoji"`
+	Emoji     string    `json:"emoji" gorm:"size:32;not null;uniqueIndex:idx_message_user_emoji"`
+}
+
+// ReactionSummary aggregates the reactions of one emoji on a message
+type ReactionSummary struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required,max=32"`
+}
+
+// Attachment is an uploaded file, linked to a message once it is sent
+type Attachment struct {
+	ID           string    `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"created_at"`
+	RoomID       string    `json:"room_id" gorm:"not null;index"`
+	UploaderID   uint      `json:"uploader_id" gorm:"not null"`
+	MessageID    *string   `json:"message_id" gorm:"index"`
+	FileName     string    `json:"file_name" gorm:"size:255;not null"`
+	ContentType  string    `json:"content_type" gorm:"size:100;not null"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	StorageKey   string    `json:"-" gorm:"size:255;not null"`
+	ThumbnailKey *string   `json:"-" gorm:"size:255"`
+	HasThumbnail bool      `json:"has_thumbnail" gorm:"-"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (a *Attachment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	a.HasThumbnail = a.ThumbnailKey != nil
+	return nil
+}
+
+// MaxPinsPerRoom caps how many messages a room can have pinned at once
+const MaxPinsPerRoom = 50
+
+// PinnedMessage keeps a message visible at the top of its room
+type PinnedMessage struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"created_at"`
+	RoomID     string    `json:"room_id" gorm:"not null;index"`
+	MessageID  string    `json:"message_id" gorm:"not null;uniqueIndex"`
+	Message    *Message  `json:"message,omitempty" gorm:"foreignKey:MessageID"`
+	PinnedByID uint      `json:"pinned_by_id" gorm:"not null"`
+}
+
+// MessageSearchResult is a matching message with its relevance and highlighted excerpt
+type MessageSearchResult struct {
+	Message *Message `json:"message"`
+	Rank    float64  `json:"rank"`
+	Snippet string   `json:"snippet"`
+}
+
+type SendMessageRequest struct {
+	Content         string   `json:"content" binding:"max=4000"`
+	ParentID        *string  `json:"parent_id"`
+	QuotedMessageID *string  `json:"quoted_message_id"`
+	AttachmentIDs   []string `json:"attachment_ids" binding:"max=10"`
+}
+
+type EditMessageRequest struct {
+	Content string `json:"content" binding:"required,max=4000"`
 }
 
 type RoomWithLastMessage struct {
 	Room
-	LastMessage *Message `json:"last_message"`
+	LastMessage *Message    `json:"last_message"`
+	UnreadCount int64       `json:"unread_count"`
+	Settings    *RoomMember `json:"settings"`
+}
+
+type TypingRequest struct {
+	Typing bool `json:"typing"`
+}
+
+type MarkRoomReadRequest struct {
+	MessageID string `json:"message_id" binding:"required"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outbox entry states
+const (
+	OutboxPending    = "pending"
+	OutboxProcessing = "processing"
+	OutboxDelivered  = "delivered"
+	OutboxDead       = "dead"
+)
+
+// OutboxEntry is a side effect recorded in the same transaction as the change that caused it,
+// delivered afterwards by the outbox dispatcher until it succeeds or runs out of attempts
+type OutboxEntry struct {
+	ID            uint            `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	Topic         string          `json:"topic" gorm:"size:50;not null;index:idx_outbox_topic_due,priority:1"`
+	Payload       json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	Status        string          `json:"status" gorm:"size:20;not null;index:idx_outbox_topic_due,priority:2"`
+	Attempts      int             `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" gorm:"not null;index:idx_outbox_topic_due,priority:3"`
+	LastError     string          `json:"last_error" gorm:"type:text"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+}
@@ -16,6 +16,79 @@ type User struct {
 	Email     string         `json:"email" gorm:"size:100;not null;uniqueIndex"`
 	Password  string         `json:"-" gorm:"size:255;not null"`
 	Age       int            `json:"age"`
+	// Admins are promoted directly in the database, there is no endpoint for it
+	IsAdmin bool `json:"-" gorm:"not null;default:false"`
+	// Bots belong to the user who created them and act through the bot API with a bot token
+	IsBot      bool  `json:"is_bot" gorm:"not null;default:false"`
+	BotOwnerID *uint `json:"bot_owner_id,omitempty" gorm:"index"`
+
+	// Settings and last seen are private: others get last seen through presence, the user
+	// themselves through OwnProfile
+	LastSeenAt   *time.Time `json:"-"`
+	HideLastSeen bool       `json:"-" gorm:"default:false"`
+	Locale       string     `json:"-" gorm:"size:10;not null;default:en"`
+
+	EmailNotifications string     `json:"-" gorm:"size:20;not null;default:off"`
+	LastDigestAt       *time.Time `json:"-"`
+
+	// Quiet hours are "15:04" times in Timezone, push is dropped and email waits while they last
+	Timezone        string  `json:"-" gorm:"size:64;not null;default:UTC"`
+	QuietHoursStart *string `json:"-" gorm:"size:5"`
+	QuietHoursEnd   *string `json:"-" gorm:"size:5"`
+}
+
+// OwnProfile is a user as they see themselves, private settings included
+type OwnProfile struct {
+	User
+	IsAdmin            bool       `json:"is_admin"`
+	LastSeenAt         *time.Time `json:"last_seen_at"`
+	HideLastSeen       bool       `json:"hide_last_seen"`
+	Locale             string     `json:"locale"`
+	EmailNotifications string     `json:"email_notifications"`
+	Timezone           string     `json:"timezone"`
+	QuietHoursStart    *string    `json:"quiet_hours_start"`
+	QuietHoursEnd      *string    `json:"quiet_hours_end"`
+}
+
+func NewOwnProfile(user User) OwnProfile {
+	return OwnProfile{
+		User:               user,
+		IsAdmin:            user.IsAdmin,
+		LastSeenAt:         user.LastSeenAt,
+		HideLastSeen:       user.HideLastSeen,
+		Locale:             user.Locale,
+		EmailNotifications: user.EmailNotifications,
+		Timezone:           user.Timezone,
+		QuietHoursStart:    user.QuietHoursStart,
+		QuietHoursEnd:      user.QuietHoursEnd,
+	}
+}
+
+// Email notification preferences
+const (
+	EmailImmediate = "immediate"
+	EmailHourly    = "hourly"
+	EmailDaily     = "daily"
+	EmailOff       = "off"
+)
+
+// UserPresence is what other users may see about someone's availability
+type UserPresence struct {
+	UserID     uint       `json:"user_id"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+}
+
+type UpdatePrivacyRequest struct {
+	HideLastSeen *bool `json:"hide_last_seen" binding:"required"`
+}
+
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" binding:"required"`
+}
+
+type UpdateEmailNotificationsRequest struct {
+	Frequency string `json:"frequency" binding:"required,oneof=immediate hourly daily off"`
 }
 
 type Notification struct {
@@ -23,8 +96,79 @@ type Notification struct {
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
 	DeletedAt gorm.DeletedAt  `json:"-" gorm:"index"`
-	UserID    uint            `json:"user_id" gorm:"not null;index"`
+	UserID    uint            `json:"user_id" gorm:"not null;index;index:idx_notifications_user_read,priority:1;index:idx_notifications_user_dedup,priority:1;index:idx_notifications_user_group,priority:1"`
+	Type      string          `json:"type" gorm:"size:50;index"`
 	Message   string          `json:"message" gorm:"size:255;not null"`
-	Metadata  json.RawMessage `json:"metadata" gorm:"type:jsonb"`
-	Read      bool            `json:"read" gorm:"default:false"`
+	Payload   json.RawMessage `json:"metadata" gorm:"column:metadata;type:jsonb"`
+	ReadAt    *time.Time      `json:"read_at" gorm:"index:idx_notifications_user_read,priority:2"`
+
+	// Notifications sharing a dedup key are refreshed in place instead of piling up,
+	// resolved ones are about something that has since been answered
+	DedupKey   *string    `json:"-" gorm:"size:100;index:idx_notifications_user_dedup,priority:2"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	EmailedAt  *time.Time `json:"-"`
+	// Hidden notifications were only meant for email or push, the in-app list leaves them out
+	Hidden bool `json:"-" gorm:"not null;default:false"`
+
+	// Similar notifications arriving close together share a group key and are collapsed into one row
+	GroupKey *string `json:"-" gorm:"size:150;index:idx_notifications_user_group,priority:2"`
+	Count    int     `json:"count" gorm:"not null;default:1"`
+	ActorIDs []uint  `json:"actor_ids,omitempty" gorm:"type:jsonb;serializer:json"`
+}
+
+// NotificationPreference turns one channel of one notification type on or off for a user.
+// Missing rows mean the channel is on.
+type NotificationPreference struct {
+	UserID    uint      `json:"-" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"primaryKey;size:50"`
+	Channel   string    `json:"channel" gorm:"primaryKey;size:20"`
+	Enabled   bool      `json:"enabled" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NotificationPreferenceRequest struct {
+	Type    string `json:"type" binding:"required"`
+	Channel string `json:"channel" binding:"required,oneof=in_app email push"`
+	Enabled *bool  `json:"enabled" binding:"required"`
+}
+
+type QuietHoursRequest struct {
+	Start string `json:"start" binding:"required,datetime=15:04"`
+	End   string `json:"end" binding:"required,datetime=15:04"`
+}
+
+// UpdateNotificationPreferencesRequest replaces all preferences, leaving quiet_hours out turns them off
+type UpdateNotificationPreferencesRequest struct {
+	Timezone    string                          `json:"timezone" binding:"required"`
+	QuietHours  *QuietHoursRequest              `json:"quiet_hours"`
+	Preferences []NotificationPreferenceRequest `json:"preferences" binding:"dive"`
+}
+
+type MarkNotificationsReadRequest struct {
+	UpToID *uint `json:"up_to_id"`
+}
+
+type DeleteNotificationsRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// PushSubscription is a browser or device registered for Web Push, one per endpoint
+type PushSubscription struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Endpoint  string    `json:"endpoint" gorm:"type:text;not null;uniqueIndex"`
+	P256dh    string    `json:"-" gorm:"size:200;not null"`
+	Auth      string    `json:"-" gorm:"size:100;not null"`
+	UserAgent string    `json:"user_agent" gorm:"size:255"`
+}
+
+// PushSubscriptionRequest matches the JSON of a browser PushSubscription
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required"`
+		Auth   string `json:"auth" binding:"required"`
+	} `json:"keys" binding:"required"`
 }
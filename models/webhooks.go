@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an endpoint of another system that receives the events it subscribed to.
+// Users get events about their own rooms and chat requests, admins get every event.
+type Webhook struct {
+	ID                  uint       `json:"id" gorm:"primarykey"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	UserID              uint       `json:"user_id" gorm:"not null;index"`
+	URL                 string     `json:"url" gorm:"type:text;not null"`
+	Description         string     `json:"description" gorm:"size:255"`
+	Secret              string     `json:"-" gorm:"size:100;not null"`
+	Events              []string   `json:"events" gorm:"type:jsonb;serializer:json;not null"`
+	Active              bool       `json:"active" gorm:"not null;default:true"`
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"not null;default:0"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+}
+
+// WebhookDelivery logs one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID           uint            `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time       `json:"created_at" gorm:"index"`
+	WebhookID    uint            `json:"webhook_id" gorm:"not null;index:idx_webhook_deliveries_event,priority:1"`
+	EventID      string          `json:"event_id" gorm:"size:36;not null;index:idx_webhook_deliveries_event,priority:2"`
+	Event        string          `json:"event" gorm:"size:50;not null"`
+	Payload      json.RawMessage `json:"payload" gorm:"type:jsonb;not null"`
+	Attempt      int             `json:"attempt" gorm:"not null"`
+	Success      bool            `json:"success" gorm:"not null"`
+	StatusCode   *int            `json:"status_code"`
+	ResponseBody string          `json:"response_body" gorm:"type:text"`
+	Error        string          `json:"error" gorm:"type:text"`
+	DurationMS   int64           `json:"duration_ms"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Description string   `json:"description" binding:"max=255"`
+	Events      []string `json:"events" binding:"required,min=1,dive,required"`
+}
+
+// UpdateWebhookRequest changes the fields that are set, activating a webhook clears its failures
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url" binding:"omitempty,url"`
+	Description *string  `json:"description" binding:"omitempty,max=255"`
+	Events      []string `json:"events" binding:"omitempty,min=1,dive,required"`
+	Active      *bool    `json:"active"`
+}
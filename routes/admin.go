@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"gin-project/handlers"
+	"gin-project/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupAdminRoutes(router *gin.Engine) {
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+	{
+		adminRoutes.GET("/outbox/", handlers.ListOutboxEntries)
+		adminRoutes.POST("/outbox/:entryID/retry/", handlers.RetryOutboxEntry)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"gin-project/handlers"
+	"gin-project/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupBotRoutes exposes the chat handlers bots may use, authenticated with a bot token
+func SetupBotRoutes(router *gin.Engine) {
+	botRoutes := router.Group("/bot")
+	botRoutes.Use(middleware.BotAuthMiddleware())
+	{
+		botRoutes.GET("/me/", handlers.GetProfile)
+		botRoutes.GET("/rooms/", handlers.ListChatRooms)
+		botRoutes.GET("/rooms/:roomID/messages/", handlers.ListMessages)
+		botRoutes.POST("/rooms/:roomID/messages/", handlers.SendMessage)
+	}
+}
@@ -16,6 +16,41 @@ func SetupChatRoutes(router *gin.Engine) {
 		protectedRoutes.GET("/received_requests/", handlers.ListReceivedChatRequests)
 		protectedRoutes.GET("/sent_requests/", handlers.ListSentChatRequests)
 		protectedRoutes.POST("/respond_request/:requestID/", handlers.RespondToChatRequest)
+		protectedRoutes.POST("/cancel_request/:requestID/", handlers.CancelChatRequest)
 		protectedRoutes.GET("/rooms/", handlers.ListChatRooms)
+		protectedRoutes.POST("/rooms/", handlers.CreateGroupRoom)
+		protectedRoutes.PATCH("/rooms/:roomID/", handlers.UpdateRoom)
+		protectedRoutes.POST("/rooms/:roomID/members/", handlers.AddRoomMembers)
+		protectedRoutes.PATCH("/rooms/:roomID/members/:userID/", handlers.UpdateMemberRole)
+		protectedRoutes.DELETE("/rooms/:roomID/members/:userID/", handlers.RemoveRoomMember)
+		protectedRoutes.POST("/rooms/:roomID/leave/", handlers.LeaveRoom)
+		protectedRoutes.POST("/rooms/:roomID/clear/", handlers.ClearConversation)
+		protectedRoutes.POST("/rooms/:roomID/invites/", handlers.CreateInvite)
+		protectedRoutes.GET("/rooms/:roomID/invites/", handlers.ListInvites)
+		protectedRoutes.DELETE("/rooms/:roomID/invites/:inviteID/", handlers.RevokeInvite)
+		protectedRoutes.GET("/rooms/:roomID/join_requests/", handlers.ListJoinRequests)
+		protectedRoutes.POST("/join_requests/:requestID/respond/", handlers.RespondToJoinRequest)
+		protectedRoutes.POST("/invites/:token/join/", handlers.JoinWithInvite)
+		protectedRoutes.GET("/rooms/:roomID/pins/", handlers.ListPinnedMessages)
+		protectedRoutes.GET("/events/", handlers.StreamEvents)
+		protectedRoutes.GET("/search/", handlers.SearchMessages)
+
+		protectedRoutes.POST("/rooms/:roomID/messages/", handlers.SendMessage)
+		protectedRoutes.GET("/rooms/:roomID/messages/", handlers.ListMessages)
+		protectedRoutes.POST("/rooms/:roomID/read/", handlers.MarkRoomRead)
+		protectedRoutes.PATCH("/rooms/:roomID/settings/", handlers.UpdateRoomSettings)
+		protectedRoutes.POST("/rooms/:roomID/typing/", handlers.SetTyping)
+		protectedRoutes.POST("/rooms/:roomID/attachments/", handlers.UploadAttachment)
+		protectedRoutes.GET("/attachments/:attachmentID/", handlers.DownloadAttachment)
+		protectedRoutes.GET("/attachments/:attachmentID/thumbnail/", handlers.DownloadThumbnail)
+		protectedRoutes.GET("/rooms/:roomID/read_receipts/", handlers.ListReadReceipts)
+		protectedRoutes.PATCH("/messages/:messageID/", handlers.EditMessage)
+		protectedRoutes.DELETE("/messages/:messageID/", handlers.DeleteMessage)
+		protectedRoutes.GET("/messages/:messageID/edits/", handlers.ListMessageEdits)
+		protectedRoutes.GET("/messages/:messageID/thread/", handlers.GetThread)
+		protectedRoutes.POST("/messages/:messageID/reactions/", handlers.AddReaction)
+		protectedRoutes.DELETE("/messages/:messageID/reactions/:emoji/", handlers.RemoveReaction)
+		protectedRoutes.POST("/messages/:messageID/pin/", handlers.PinMessage)
+		protectedRoutes.DELETE("/messages/:messageID/pin/", handlers.UnpinMessage)
 	}
 }
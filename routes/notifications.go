@@ -12,6 +12,14 @@ func SetupNotificationRoutes(router *gin.Engine) {
 	protectedRoutes.Use(middleware.AuthMiddleware())
 	{
 		protectedRoutes.GET("/", handlers.ListNotifications)
+		protectedRoutes.GET("/unread_count/", handlers.GetUnreadNotificationCount)
 		protectedRoutes.POST("/mark_read/:notificationID/", handlers.MarkNotificationAsRead)
+		protectedRoutes.POST("/mark_all_read/", handlers.MarkAllNotificationsAsRead)
+		protectedRoutes.POST("/delete/", handlers.DeleteNotifications)
+
+		protectedRoutes.GET("/push/vapid_public_key/", handlers.GetVAPIDPublicKey)
+		protectedRoutes.GET("/push/subscriptions/", handlers.ListPushSubscriptions)
+		protectedRoutes.POST("/push/subscriptions/", handlers.RegisterPushSubscription)
+		protectedRoutes.DELETE("/push/subscriptions/:subscriptionID/", handlers.DeletePushSubscription)
 	}
 }
@@ -12,5 +12,25 @@ func SetupProtectedRoutes(router *gin.Engine) {
 	protectedRoutes.Use(middleware.AuthMiddleware())
 	{
 		protectedRoutes.GET("/profile", handlers.GetProfile)
+		protectedRoutes.PATCH("/profile/privacy", handlers.UpdatePrivacy)
+		protectedRoutes.PATCH("/profile/locale", handlers.UpdateLocale)
+		protectedRoutes.PATCH("/profile/email_notifications", handlers.UpdateEmailNotifications)
+		protectedRoutes.GET("/presence", handlers.GetPresence)
+		protectedRoutes.GET("/notification_preferences", handlers.GetNotificationPreferences)
+		protectedRoutes.PUT("/notification_preferences", handlers.UpdateNotificationPreferences)
+
+		protectedRoutes.GET("/webhooks/events", handlers.ListWebhookEvents)
+		protectedRoutes.GET("/webhooks", handlers.ListWebhooks)
+		protectedRoutes.POST("/webhooks", handlers.CreateWebhook)
+		protectedRoutes.PATCH("/webhooks/:webhookID", handlers.UpdateWebhook)
+		protectedRoutes.DELETE("/webhooks/:webhookID", handlers.DeleteWebhook)
+		protectedRoutes.GET("/webhooks/:webhookID/deliveries", handlers.ListWebhookDeliveries)
+		protectedRoutes.POST("/webhooks/:webhookID/deliveries/:deliveryID/redeliver", handlers.RedeliverWebhookDelivery)
+
+		protectedRoutes.GET("/bots", handlers.ListBots)
+		protectedRoutes.POST("/bots", handlers.CreateBot)
+		protectedRoutes.PATCH("/bots/:botID", handlers.UpdateBot)
+		protectedRoutes.DELETE("/bots/:botID", handlers.DeleteBot)
+		protectedRoutes.POST("/bots/:botID/token", handlers.RegenerateBotToken)
 	}
 }
@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"gin-project/handlers"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,4 +21,8 @@ func SetupPublicRoutes(router *gin.Engine) {
 			"database": "connected",
 		})
 	})
+
+	// Unsubscribe links in emails are signed, so they work without a login
+	router.GET("/email/unsubscribe", handlers.ConfirmUnsubscribeEmail)
+	router.POST("/email/unsubscribe", handlers.UnsubscribeEmail)
 }
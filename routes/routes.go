@@ -10,4 +10,6 @@ func SetupAllRoutes(router *gin.Engine) {
 	SetupProtectedRoutes(router)
 	SetupChatRoutes(router)
 	SetupNotificationRoutes(router)
+	SetupAdminRoutes(router)
+	SetupBotRoutes(router)
 }
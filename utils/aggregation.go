@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// notificationAggregation collapses notifications of one type that arrive within window and share
+// a group into a single row, counting the events and the distinct people behind them
+type notificationAggregation struct {
+	window time.Duration
+	// byPeople templates talk about the other people involved, they need two distinct actors
+	byPeople  bool
+	group     func(NotificationPayload) string
+	actor     func(NotificationPayload) uint
+	templates map[string]*template.Template
+}
+
+// aggregateData is what aggregated templates render, Payload is the latest event
+type aggregateData struct {
+	Payload NotificationPayload
+	Count   int
+	Others  int
+}
+
+var notificationAggregations = map[string]*notificationAggregation{}
+
+func registerNotificationAggregation(notificationType string, window time.Duration, byPeople bool, group func(NotificationPayload) string,
+	actor func(NotificationPayload) uint, translations map[string]string) {
+	if _, ok := translations[DefaultLocale]; !ok {
+		panic(fmt.Sprintf("notification aggregation %s has no %s template", notificationType, DefaultLocale))
+	}
+
+	templates := make(map[string]*template.Template, len(translations))
+	for locale, text := range translations {
+		templates[locale] = template.Must(template.New(notificationType + ".aggregate." + locale).Parse(text))
+	}
+	notificationAggregations[notificationType] = &notificationAggregation{
+		window:    window,
+		byPeople:  byPeople,
+		group:     group,
+		actor:     actor,
+		templates: templates,
+	}
+}
+
+func init() {
+	registerNotificationAggregation(NotificationChatRequestAccepted, time.Hour, true,
+		func(NotificationPayload) string { return "" },
+		func(p NotificationPayload) uint { return p.(ChatRequestAcceptedPayload).ReceiverID },
+		map[string]string{
+			LocaleEnglish: "{{.Payload.ReceiverName}} and {{.Others}} {{if eq .Others 1}}other{{else}}others{{end}} accepted your chat requests.",
+			LocaleSpanish: "{{.Payload.ReceiverName}} y {{.Others}} {{if eq .Others 1}}persona más{{else}}personas más{{end}} aceptaron tus solicitudes de chat.",
+			LocaleFrench:  "{{.Payload.ReceiverName}} et {{.Others}} {{if eq .Others 1}}autre personne ont{{else}}autres personnes ont{{end}} accepté vos demandes de discussion.",
+		})
+	registerNotificationAggregation(NotificationJoinRequestReceived, time.Hour, true,
+		func(p NotificationPayload) string { return p.(JoinRequestReceivedPayload).RoomID },
+		func(p NotificationPayload) uint { return p.(JoinRequestReceivedPayload).UserID },
+		map[string]string{
+			LocaleEnglish: "{{.Payload.UserName}} and {{.Others}} {{if eq .Others 1}}other{{else}}others{{end}} asked to join {{.Payload.RoomName}}.",
+			LocaleSpanish: "{{.Payload.UserName}} y {{.Others}} {{if eq .Others 1}}persona más{{else}}personas más{{end}} pidieron unirse a {{.Payload.RoomName}}.",
+			LocaleFrench:  "{{.Payload.UserName}} et {{.Others}} {{if eq .Others 1}}autre personne ont{{else}}autres personnes ont{{end}} demandé à rejoindre {{.Payload.RoomName}}.",
+		})
+	registerNotificationAggregation(NotificationNewMessage, time.Hour, false,
+		func(p NotificationPayload) string { return p.(NewMessagePayload).RoomID },
+		func(p NotificationPayload) uint { return p.(NewMessagePayload).SenderID },
+		map[string]string{
+			LocaleEnglish: "{{.Count}} new messages from {{.Payload.SenderName}}{{if .Others}} and {{.Others}} {{if eq .Others 1}}other{{else}}others{{end}}{{end}}.",
+			LocaleSpanish: "{{.Count}} mensajes nuevos de {{.Payload.SenderName}}{{if .Others}} y {{.Others}} {{if eq .Others 1}}persona más{{else}}personas más{{end}}{{end}}.",
+			LocaleFrench:  "{{.Count}} nouveaux messages de {{.Payload.SenderName}}{{if .Others}} et {{.Others}} {{if eq .Others 1}}autre personne{{else}}autres personnes{{end}}{{end}}.",
+		})
+}
+
+// notificationGroup returns the aggregation of the payload type and the group key rows are collapsed
+// by, or nil when the type isn't aggregated
+func notificationGroup(payload NotificationPayload) (*notificationAggregation, string) {
+	aggregation, ok := notificationAggregations[payload.NotificationType()]
+	if !ok {
+		return nil, ""
+	}
+	return aggregation, payload.NotificationType() + ":" + aggregation.group(payload)
+}
+
+// render renders the collapsed text of a notification standing for count events.
+// A repeat from the only actor so far still reads as the single event for types counting people.
+func (a *notificationAggregation) render(payload NotificationPayload, locale string, count int, actorIDs []uint) (string, error) {
+	if a.byPeople && len(actorIDs) < 2 {
+		return RenderNotification(payload, locale)
+	}
+
+	tmpl, ok := a.templates[locale]
+	if !ok {
+		tmpl = a.templates[DefaultLocale]
+	}
+
+	var text strings.Builder
+	err := tmpl.Execute(&text, aggregateData{Payload: payload, Count: count, Others: len(actorIDs) - 1})
+	return text.String(), err
+}
+
+// addActor appends actorID to actorIDs unless it is already there
+func addActor(actorIDs []uint, actorID uint) []uint {
+	if slices.Contains(actorIDs, actorID) {
+		return actorIDs
+	}
+	return append(actorIDs, actorID)
+}
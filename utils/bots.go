@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"gorm.io/gorm"
+)
+
+// botWebhookEvents are the events a bot's webhook gets about the rooms it was added to
+var botWebhookEvents = []string{WebhookMessageCreated}
+
+// GenerateBotToken returns a new bot token and the hash stored for it
+func GenerateBotToken() (string, string, error) {
+	random, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	token := "bot_" + random
+	return token, HashBotToken(token), nil
+}
+
+// HashBotToken hashes a bot token for lookup, tokens are random enough not to need a slow hash
+func HashBotToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CanAddBots reports whether every bot among userIDs belongs to ownerID, only owners put their bots in rooms
+func CanAddBots(userIDs []uint, ownerID uint) bool {
+	var count int64
+	config.DB.Model(&models.User{}).Where("id IN ? AND is_bot AND bot_owner_id <> ?", userIDs, ownerID).Count(&count)
+	return count == 0
+}
+
+// SetBotWebhook points the bot's webhook at url in tx, creating it when the bot has none and
+// deleting it when url is empty. The signing secret is returned when a webhook is created.
+// URLs that ValidateWebhookURL rejects return ErrUnsafeWebhookURL.
+func SetBotWebhook(tx *gorm.DB, botID uint, url string) (string, error) {
+	if url != "" {
+		if err := ValidateWebhookURL(url); err != nil {
+			return "", err
+		}
+	}
+
+	var webhook models.Webhook
+	err := tx.Where("user_id = ?", botID).First(&webhook).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	exists := err == nil
+
+	switch {
+	case url == "" && exists:
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return "", err
+		}
+		return "", tx.Delete(&webhook).Error
+	case url == "":
+		return "", nil
+	case exists:
+		return "", tx.Model(&webhook).Updates(map[string]any{
+			"url":                  url,
+			"active":               true,
+			"consecutive_failures": 0,
+			"disabled_at":          nil,
+		}).Error
+	}
+
+	secret, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	webhook = models.Webhook{
+		UserID:      botID,
+		URL:         url,
+		Description: "Bot messages",
+		Secret:      "whsec_" + secret,
+		Events:      botWebhookEvents,
+		Active:      true,
+	}
+	if err := tx.Create(&webhook).Error; err != nil {
+		return "", err
+	}
+	return webhook.Secret, nil
+}
+
+// BotWebhookURLs returns the webhook URL of each of the bots that has one
+func BotWebhookURLs(botIDs []uint) (map[uint]string, error) {
+	urls := make(map[uint]string)
+	if len(botIDs) == 0 {
+		return urls, nil
+	}
+
+	var webhooks []models.Webhook
+	if err := config.DB.Where("user_id IN ?", botIDs).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	for _, webhook := range webhooks {
+		urls[webhook.UserID] = webhook.URL
+	}
+	return urls, nil
+}
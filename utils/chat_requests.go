@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"gorm.io/gorm"
+)
+
+// chatRequestNotificationWindow is how long re-requests from the same sender update the
+// receiver's existing notification instead of creating another one
+const chatRequestNotificationWindow = 24 * time.Hour
+
+// ChatRequestNotificationKey is the dedup key of the notifications a receiver gets about requests from senderID
+func ChatRequestNotificationKey(senderID uint) string {
+	return fmt.Sprintf("chat_request:%d", senderID)
+}
+
+// NotifyChatRequestReceived tells the receiver about a request in tx, refreshing the previous
+// notification from the same sender rather than adding one per re-request
+func NotifyChatRequestReceived(tx *gorm.DB, chatRequest models.ChatRequest) error {
+	_, err := UpsertNotification(tx, chatRequest.ReceiverID, ChatRequestNotificationKey(chatRequest.SenderID), chatRequestNotificationWindow,
+		ChatRequestReceivedPayload{
+			ChatRequestID: chatRequest.ID,
+			SenderID:      chatRequest.SenderID,
+			SenderName:    chatRequest.Sender.Name,
+		})
+	return err
+}
+
+// NotifyChatRequestCancelled resolves the receiver's notification about the request in tx and
+// replaces it with an unread cancellation notice, which is resolved from the start
+func NotifyChatRequestCancelled(tx *gorm.DB, chatRequest models.ChatRequest) error {
+	key := ChatRequestNotificationKey(chatRequest.SenderID)
+	if err := ResolveNotifications(tx, chatRequest.ReceiverID, key); err != nil {
+		return err
+	}
+
+	now := GetCurrentTimestamp()
+	_, err := upsertNotification(tx, chatRequest.ReceiverID, key, chatRequestNotificationWindow,
+		ChatRequestCancelledPayload{
+			ChatRequestID: chatRequest.ID,
+			SenderID:      chatRequest.SenderID,
+			SenderName:    chatRequest.Sender.Name,
+		}, &now)
+	return err
+}
+
+// EnqueueChatRequestWebhook records in tx a webhook event about the request for its sender and receiver.
+// The request is reloaded with both users, so the payload is what tx wrote whatever the caller preloaded.
+// Senders only hear about a rejection when NotifyRejectedChatRequests is on.
+func EnqueueChatRequestWebhook(tx *gorm.DB, event string, chatRequest models.ChatRequest) error {
+	if err := tx.Preload("Sender").Preload("Receiver").First(&chatRequest, "id = ?", chatRequest.ID).Error; err != nil {
+		return err
+	}
+
+	userIDs := []uint{chatRequest.SenderID, chatRequest.ReceiverID}
+	if event == WebhookChatRequestRejected && !NotifyRejectedChatRequests() {
+		userIDs = []uint{chatRequest.ReceiverID}
+	}
+	return EnqueueWebhookEvent(tx, event, userIDs, chatRequest)
+}
+
+// NotifyRejectedChatRequests reports whether senders are told their request was declined.
+// Off by default so declining stays discreet, enabled with CHAT_REQUEST_NOTIFY_REJECTED=true.
+func NotifyRejectedChatRequests() bool {
+	notify, _ := strconv.ParseBool(os.Getenv("CHAT_REQUEST_NOTIFY_REJECTED"))
+	return notify
+}
+
+// chatRequestTTL is how long a request stays pending, CHAT_REQUEST_TTL_HOURS defaults to a week
+func chatRequestTTL() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("CHAT_REQUEST_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 7 * 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// ExpireChatRequests marks pending requests older than the TTL as expired, resolves the receivers'
+// notifications and tells the senders
+func ExpireChatRequests() error {
+	var chatRequests []models.ChatRequest
+	err := config.DB.Preload("Receiver").
+		Where("status = ? AND created_at < ?", "pending", GetCurrentTimestamp().Add(-chatRequestTTL())).
+		Find(&chatRequests).Error
+	if err != nil {
+		return err
+	}
+
+	for _, chatRequest := range chatRequests {
+		err := OutboxTransaction(func(tx *gorm.DB) error {
+			// The status guard keeps a request answered in the meantime from being expired
+			result := tx.Model(&models.ChatRequest{}).Where("id = ? AND status = ?", chatRequest.ID, "pending").
+				Updates(map[string]any{"status": "expired", "updated_at": GetCurrentTimestamp()})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			if err := ResolveNotifications(tx, chatRequest.ReceiverID, ChatRequestNotificationKey(chatRequest.SenderID)); err != nil {
+				return err
+			}
+			chatRequest.Status = "expired"
+			if err := EnqueueChatRequestWebhook(tx, WebhookChatRequestExpired, chatRequest); err != nil {
+				return err
+			}
+
+			_, err := CreateNotificationTx(tx, chatRequest.SenderID, ChatRequestExpiredPayload{
+				ChatRequestID: chatRequest.ID,
+				ReceiverID:    chatRequest.ReceiverID,
+				ReceiverName:  chatRequest.Receiver.Name,
+			})
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to expire chat request %s: %v", chatRequest.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// StartChatRequestExpiry runs ExpireChatRequests every interval in the background
+func StartChatRequestExpiry(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := ExpireChatRequests(); err != nil {
+				log.Printf("Failed to expire chat requests: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
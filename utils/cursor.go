@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// KeysetSort describes the order of a keyset paginated list. The ID column breaks ties so every row has a unique position.
+type KeysetSort struct {
+	Column   string
+	IDColumn string
+	Desc     bool
+}
+
+// CursorParams holds the cursor pagination parameters of a request
+type CursorParams struct {
+	Cursor   string
+	PageSize int
+}
+
+// CursorResult holds cursor pagination metadata
+type CursorResult struct {
+	PageSize   int     `json:"page_size"`
+	NextCursor *string `json:"next_cursor"`
+	PrevCursor *string `json:"prev_cursor"`
+	HasNext    bool    `json:"has_next"`
+	HasPrev    bool    `json:"has_prev"`
+}
+
+// cursorPayload is what an opaque cursor carries, the sort column is kept so a cursor can't be replayed against another order
+type cursorPayload struct {
+	Column   string    `json:"c"`
+	Value    cursorKey `json:"v"`
+	ID       cursorKey `json:"i"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+type cursorKey struct {
+	Kind  string `json:"k"`
+	Value string `json:"v"`
+}
+
+// GetCursorParams extracts cursor pagination parameters from Gin context
+func GetCursorParams(c *gin.Context) CursorParams {
+	pageSize := 10 // default page size, same bounds as GetPaginationParams
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 && ps <= 100 {
+			pageSize = ps
+		}
+	}
+
+	return CursorParams{
+		Cursor:   c.Query("cursor"),
+		PageSize: pageSize,
+	}
+}
+
+// KeysetPaginate fetches one page of db in the given order, starting after params.Cursor.
+// key returns the sort value and ID of a row and is used to build the next and previous cursors.
+// Unlike Paginate it never counts or offsets, so the cost of a page doesn't grow with the table.
+func KeysetPaginate[T any](db *gorm.DB, params CursorParams, sort KeysetSort, key func(T) (any, any)) ([]T, CursorResult, error) {
+	result := CursorResult{PageSize: params.PageSize}
+
+	var cursor *cursorPayload
+	if params.Cursor != "" {
+		decoded, err := decodeCursor(params.Cursor)
+		if err != nil || decoded.Column != sort.Column {
+			return nil, result, ErrInvalidCursor
+		}
+		cursor = decoded
+	}
+
+	backward := cursor != nil && cursor.Backward
+	// Walking backwards flips the order, the page is reversed again once fetched
+	desc := sort.Desc != backward
+	direction := "ASC"
+	comparison := ">"
+	if desc {
+		direction = "DESC"
+		comparison = "<"
+	}
+
+	query := db
+	if cursor != nil {
+		value, err := cursor.Value.decode()
+		if err != nil {
+			return nil, result, ErrInvalidCursor
+		}
+		id, err := cursor.ID.decode()
+		if err != nil {
+			return nil, result, ErrInvalidCursor
+		}
+		query = query.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", sort.Column, sort.IDColumn, comparison), value, id)
+	}
+
+	// One extra row tells whether there is another page in this direction
+	var rows []T
+	err := query.Order(sort.Column + " " + direction).Order(sort.IDColumn + " " + direction).
+		Limit(params.PageSize + 1).Find(&rows).Error
+	if err != nil {
+		return nil, result, err
+	}
+
+	more := len(rows) > params.PageSize
+	if more {
+		rows = rows[:params.PageSize]
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		result.HasPrev = more
+		result.HasNext = true
+	} else {
+		result.HasNext = more
+		result.HasPrev = cursor != nil
+	}
+
+	if len(rows) == 0 {
+		return rows, result, nil
+	}
+
+	if result.HasNext {
+		value, id := key(rows[len(rows)-1])
+		next, err := encodeCursor(sort.Column, value, id, false)
+		if err != nil {
+			return nil, result, err
+		}
+		result.NextCursor = &next
+	}
+	if result.HasPrev {
+		value, id := key(rows[0])
+		prev, err := encodeCursor(sort.Column, value, id, true)
+		if err != nil {
+			return nil, result, err
+		}
+		result.PrevCursor = &prev
+	}
+
+	return rows, result, nil
+}
+
+func newCursorKey(v any) (cursorKey, error) {
+	switch value := v.(type) {
+	case time.Time:
+		return cursorKey{Kind: "time", Value: value.UTC().Format(time.RFC3339Nano)}, nil
+	case string:
+		return cursorKey{Kind: "string", Value: value}, nil
+	case int:
+		return cursorKey{Kind: "int", Value: strconv.FormatInt(int64(value), 10)}, nil
+	case int64:
+		return cursorKey{Kind: "int", Value: strconv.FormatInt(value, 10)}, nil
+	case uint:
+		return cursorKey{Kind: "uint", Value: strconv.FormatUint(uint64(value), 10)}, nil
+	case uint64:
+		return cursorKey{Kind: "uint", Value: strconv.FormatUint(value, 10)}, nil
+	}
+	return cursorKey{}, fmt.Errorf("unsupported cursor value type %T", v)
+}
+
+func (k cursorKey) decode() (any, error) {
+	switch k.Kind {
+	case "time":
+		return time.Parse(time.RFC3339Nano, k.Value)
+	case "string":
+		return k.Value, nil
+	case "int":
+		return strconv.ParseInt(k.Value, 10, 64)
+	case "uint":
+		return strconv.ParseUint(k.Value, 10, 64)
+	}
+	return nil, ErrInvalidCursor
+}
+
+func encodeCursor(column string, value, id any, backward bool) (string, error) {
+	valueKey, err := newCursorKey(value)
+	if err != nil {
+		return "", err
+	}
+	idKey, err := newCursorKey(id)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(cursorPayload{Column: column, Value: valueKey, ID: idKey, Backward: backward})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + signCursor(encoded), nil
+}
+
+func decodeCursor(cursor string) (*cursorPayload, error) {
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signCursor(encoded))) {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var decoded cursorPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &decoded, nil
+}
+
+// signCursor keeps clients from forging cursors that point at arbitrary sort values
+func signCursor(encoded string) string {
+	secret := []byte(os.Getenv("CURSOR_SECRET"))
+	if len(secret) == 0 {
+		secret = getJWTSecret()
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gin-project/config"
+	"gin-project/mailer"
+	"gin-project/models"
+
+	"gorm.io/gorm"
+)
+
+// digestLimit is how many notifications a single digest lists, the rest are summed up as "more"
+const digestLimit = 20
+
+//go:embed templates/digest.txt templates/digest.html templates/unsubscribe.html
+var digestTemplateFiles embed.FS
+
+var (
+	digestTextTemplate  = texttemplate.Must(texttemplate.ParseFS(digestTemplateFiles, "templates/digest.txt"))
+	digestHTMLTemplate  = htmltemplate.Must(htmltemplate.ParseFS(digestTemplateFiles, "templates/digest.html"))
+	unsubscribeTemplate = htmltemplate.Must(htmltemplate.ParseFS(digestTemplateFiles, "templates/unsubscribe.html"))
+)
+
+type digestData struct {
+	Name           string
+	Count          int64
+	More           int64
+	Notifications  []models.Notification
+	Frequency      string
+	AppURL         string
+	UnsubscribeURL string
+}
+
+// digestInterval is the least time between two digests for an email preference
+func digestInterval(frequency string) time.Duration {
+	switch frequency {
+	case models.EmailHourly:
+		return time.Hour
+	case models.EmailDaily:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+func frequencyDescription(frequency string) string {
+	switch frequency {
+	case models.EmailHourly:
+		return "at most once an hour"
+	case models.EmailDaily:
+		return "at most once a day"
+	}
+	return "as notifications arrive"
+}
+
+// AppURL is the public address of the app used in links sent out of band
+func AppURL() string {
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:8080"
+	}
+	return strings.TrimRight(appURL, "/")
+}
+
+// SendEmailDigests emails every offline user whose preference is due their unread notifications
+// that haven't been emailed yet
+func SendEmailDigests() error {
+	if config.Mailer == nil {
+		return nil
+	}
+
+	var users []models.User
+	err := config.DB.Where("email_notifications <> ?", models.EmailOff).
+		Where("EXISTS (?)", config.DB.Model(&models.Notification{}).Select("1").
+			Where("notifications.user_id = users.id AND notifications.read_at IS NULL AND notifications.emailed_at IS NULL")).
+		Find(&users).Error
+	if err != nil {
+		return err
+	}
+
+	now := GetCurrentTimestamp()
+	for _, user := range users {
+		if user.LastDigestAt != nil && now.Sub(*user.LastDigestAt) < digestInterval(user.EmailNotifications) {
+			continue
+		}
+		// Users with the app open already see their notifications, quiet hours hold the digest back
+		if Hub.ConnectionCount(user.ID) > 0 || InQuietHours(user, now) {
+			continue
+		}
+
+		if err := sendEmailDigest(user, now); err != nil {
+			log.Printf("Failed to send email digest to user %d: %v", user.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func sendEmailDigest(user models.User, now time.Time) error {
+	pending := func(db *gorm.DB) *gorm.DB {
+		return db.Model(&models.Notification{}).
+			Where("user_id = ? AND read_at IS NULL AND emailed_at IS NULL AND created_at <= ?", user.ID, now)
+	}
+
+	var count int64
+	if err := pending(config.DB).Count(&count).Error; err != nil {
+		return err
+	}
+
+	var notifications []models.Notification
+	if err := pending(config.DB).Order("created_at DESC").Limit(digestLimit).Find(&notifications).Error; err != nil {
+		return err
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	data := digestData{
+		Name:           user.Name,
+		Count:          count,
+		More:           count - int64(len(notifications)),
+		Notifications:  notifications,
+		Frequency:      frequencyDescription(user.EmailNotifications),
+		AppURL:         AppURL(),
+		UnsubscribeURL: UnsubscribeURL(user.ID),
+	}
+
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, data); err != nil {
+		return err
+	}
+	if err := digestHTMLTemplate.Execute(&html, data); err != nil {
+		return err
+	}
+
+	subject := "You have a new notification"
+	if count > 1 {
+		subject = fmt.Sprintf("You have %d new notifications", count)
+	}
+
+	// The digest is queued with the bookkeeping so it is neither lost nor sent twice
+	return OutboxTransaction(func(tx *gorm.DB) error {
+		// Everything pending counts as emailed, including what didn't fit in the digest
+		if err := pending(tx).Update("emailed_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("last_digest_at", now).Error; err != nil {
+			return err
+		}
+
+		return EnqueueOutbox(tx, OutboxTopicEmail, mailer.Message{
+			To:      user.Email,
+			Subject: subject,
+			Text:    text.String(),
+			HTML:    html.String(),
+			Headers: map[string]string{
+				// One-click unsubscribe from the mail client (RFC 8058)
+				"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+				"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			},
+		})
+	})
+}
+
+func init() {
+	RegisterOutboxHandler(OutboxTopicEmail, func(ctx context.Context, entry models.OutboxEntry) error {
+		if config.Mailer == nil {
+			return errors.New("SMTP is not configured")
+		}
+
+		var message mailer.Message
+		if err := json.Unmarshal(entry.Payload, &message); err != nil {
+			return err
+		}
+		return config.Mailer.Send(message)
+	})
+}
+
+// StartEmailDigests runs SendEmailDigests every interval in the background
+func StartEmailDigests(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := SendEmailDigests(); err != nil {
+				log.Printf("Failed to send email digests: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// UnsubscribeURL is the link that turns off email notifications for the user without logging in
+func UnsubscribeURL(userID uint) string {
+	return AppURL() + "/email/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(userID))
+}
+
+// RenderUnsubscribePage writes the page behind an unsubscribe link: a form confirming the
+// unsubscribe for token, or the confirmation once done
+func RenderUnsubscribePage(w io.Writer, token string, done bool) error {
+	return unsubscribeTemplate.Execute(w, struct {
+		Done   bool
+		Action string
+		AppURL string
+	}{
+		Done:   done,
+		Action: "/email/unsubscribe?token=" + url.QueryEscape(token),
+		AppURL: AppURL(),
+	})
+}
+
+// UnsubscribeToken signs the user ID so unsubscribe links can't be forged for other users
+func UnsubscribeToken(userID uint) string {
+	id := strconv.FormatUint(uint64(userID), 10)
+	return id + "." + signUnsubscribe(id)
+}
+
+// ParseUnsubscribeToken returns the user ID of a token made by UnsubscribeToken
+func ParseUnsubscribeToken(token string) (uint, bool) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signUnsubscribe(id))) {
+		return 0, false
+	}
+
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func signUnsubscribe(id string) string {
+	mac := hmac.New(sha256.New, getJWTSecret())
+	mac.Write([]byte("unsubscribe:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
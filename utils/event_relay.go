@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// eventsChannel is the Postgres NOTIFY channel that carries the ids of dispatched real-time events
+const eventsChannel = "outbox_events"
+
+// StartEventRelay publishes the real-time events dispatched by any app instance to the connections
+// held by this one. Events dispatched while the listener is reconnecting are missed.
+func StartEventRelay() {
+	go func() {
+		for {
+			if err := relayEvents(context.Background()); err != nil {
+				log.Printf("Event relay disconnected: %v", err)
+			}
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+func relayEvents(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, config.DSN)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+eventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		id, err := strconv.ParseUint(notification.Payload, 10, 64)
+		if err != nil {
+			log.Printf("Ignoring malformed event notification %q", notification.Payload)
+			continue
+		}
+		var entry models.OutboxEntry
+		if err := config.DB.Select("id", "payload").First(&entry, id).Error; err != nil {
+			log.Printf("Failed to load event %d: %v", id, err)
+			continue
+		}
+		if err := publishOutboxEvent(entry.Payload); err != nil {
+			log.Printf("Failed to publish event %d: %v", id, err)
+		}
+	}
+}
+
+// publishOutboxEvent delivers a recorded real-time event to the local connections it is addressed to
+func publishOutboxEvent(payload json.RawMessage) error {
+	var entry struct {
+		UserID uint   `json:"user_id"`
+		RoomID string `json:"room_id"`
+		Event  struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return err
+	}
+
+	// Data stays raw so clients get exactly what was recorded
+	event := Event{Type: entry.Event.Type, Data: entry.Event.Data}
+	if entry.RoomID != "" {
+		Hub.PublishToRoom(entry.RoomID, event)
+	} else {
+		Hub.PublishToUser(entry.UserID, event)
+	}
+	return nil
+}
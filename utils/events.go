@@ -0,0 +1,88 @@
+package utils
+
+import "sync"
+
+// Event is a real-time update pushed to connected clients
+type Event struct {
+	Type   string `json:"type"`
+	RoomID string `json:"room_id,omitempty"`
+	Data   any    `json:"data,omitempty"`
+}
+
+// EventHub fans events out to every open connection of a user
+type EventHub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan Event]struct{}
+}
+
+var Hub = NewEventHub()
+
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subscribers: make(map[uint]map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers a new connection for the user and returns its event channel.
+// first is true when this is the user's only open connection.
+func (h *EventHub) Subscribe(userID uint) (ch chan Event, first bool) {
+	ch = make(chan Event, 32)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+
+	return ch, len(h.subscribers[userID]) == 1
+}
+
+// Unsubscribe removes a connection and closes its channel.
+// It reports whether that was the user's last open connection.
+func (h *EventHub) Unsubscribe(userID uint, ch chan Event) (last bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[userID][ch]; !ok {
+		return false
+	}
+	delete(h.subscribers[userID], ch)
+	if len(h.subscribers[userID]) == 0 {
+		delete(h.subscribers, userID)
+		last = true
+	}
+	close(ch)
+	return last
+}
+
+// ConnectionCount returns how many open connections a user currently holds
+func (h *EventHub) ConnectionCount(userID uint) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subscribers[userID])
+}
+
+// PublishToUser delivers an event to all of a user's connections.
+// Slow connections whose buffer is full miss the event instead of blocking the sender.
+func (h *EventHub) PublishToUser(userID uint, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishToRoom delivers an event to every member of a room
+func (h *EventHub) PublishToRoom(roomID string, event Event) {
+	event.RoomID = roomID
+	for _, userID := range GetRoomMemberIDs(roomID) {
+		h.PublishToUser(userID, event)
+	}
+}
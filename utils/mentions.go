@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"gorm.io/gorm"
+)
+
+// ParseMentions finds @Name mentions of the given users in the content.
+// Names are matched case-insensitively, preferring the longest name when several match.
+func ParseMentions(content string, candidates []models.User) []models.MessageMention {
+	users := make([]models.User, len(candidates))
+	copy(users, candidates)
+	sort.Slice(users, func(i, j int) bool {
+		return len([]rune(users[i].Name)) > len([]rune(users[j].Name))
+	})
+
+	runes := []rune(content)
+	var mentions []models.MessageMention
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isMentionRune(runes[i-1])) {
+			continue
+		}
+
+		for _, user := range users {
+			name := []rune(user.Name)
+			end := i + 1 + len(name)
+			if len(name) == 0 || end > len(runes) {
+				continue
+			}
+			if !strings.EqualFold(string(runes[i+1:end]), user.Name) {
+				continue
+			}
+			if end < len(runes) && isMentionRune(runes[end]) {
+				continue
+			}
+
+			mentions = append(mentions, models.MessageMention{
+				UserID: user.ID,
+				Offset: i,
+				Length: end - i,
+			})
+			i = end - 1
+			break
+		}
+	}
+	return mentions
+}
+
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// MentionRoomMembers parses mentions of the room's other members
+func MentionRoomMembers(roomID string, senderID uint, content string) []models.MessageMention {
+	var members []models.User
+	config.DB.Where("id IN (?) AND id <> ?",
+		config.DB.Table("room_members").Select("user_id").Where("room_id = ?", roomID), senderID).
+		Find(&members)
+
+	return ParseMentions(content, members)
+}
+
+// NotifyMentions creates a mention notification in tx for each user mentioned in the message,
+// skipping users listed in alreadyNotified and users whose room settings silence mentions
+func NotifyMentions(tx *gorm.DB, message models.Message, senderName string, alreadyNotified map[uint]bool) error {
+	var mentionedIDs []uint
+	for _, mention := range message.Mentions {
+		if !alreadyNotified[mention.UserID] {
+			mentionedIDs = append(mentionedIDs, mention.UserID)
+		}
+	}
+
+	for _, userID := range RoomNotificationRecipients(message.RoomID, mentionedIDs, true) {
+		_, err := CreateNotificationTx(tx, userID, MentionPayload{
+			RoomID:     message.RoomID,
+			MessageID:  message.ID,
+			SenderID:   message.SenderID,
+			SenderName: senderName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
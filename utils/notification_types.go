@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Supported notification locales, DefaultLocale is used when a template has no translation
+const (
+	LocaleEnglish = "en"
+	LocaleSpanish = "es"
+	LocaleFrench  = "fr"
+
+	DefaultLocale = LocaleEnglish
+)
+
+// NotificationPayload is the typed data of a notification. Fields are validated with binding tags
+// when the notification is created and the payload is returned to clients as is.
+type NotificationPayload interface {
+	NotificationType() string
+}
+
+type ChatRequestReceivedPayload struct {
+	ChatRequestID string `json:"chat_request_id" binding:"required"`
+	SenderID      uint   `json:"sender_id" binding:"required"`
+	SenderName    string `json:"sender_name" binding:"required"`
+}
+
+func (ChatRequestReceivedPayload) NotificationType() string { return NotificationChatRequestReceived }
+
+type ChatRequestAcceptedPayload struct {
+	ChatRequestID string `json:"chat_request_id" binding:"required"`
+	RoomID        string `json:"room_id" binding:"required"`
+	ReceiverID    uint   `json:"receiver_id" binding:"required"`
+	ReceiverName  string `json:"receiver_name" binding:"required"`
+}
+
+func (ChatRequestAcceptedPayload) NotificationType() string { return NotificationChatRequestAccepted }
+
+type ChatRequestRejectedPayload struct {
+	ChatRequestID string `json:"chat_request_id" binding:"required"`
+	ReceiverID    uint   `json:"receiver_id" binding:"required"`
+	ReceiverName  string `json:"receiver_name" binding:"required"`
+}
+
+func (ChatRequestRejectedPayload) NotificationType() string { return NotificationChatRequestRejected }
+
+type ChatRequestCancelledPayload struct {
+	ChatRequestID string `json:"chat_request_id" binding:"required"`
+	SenderID      uint   `json:"sender_id" binding:"required"`
+	SenderName    string `json:"sender_name" binding:"required"`
+}
+
+func (ChatRequestCancelledPayload) NotificationType() string { return NotificationChatRequestCancelled }
+
+type ChatRequestExpiredPayload struct {
+	ChatRequestID string `json:"chat_request_id" binding:"required"`
+	ReceiverID    uint   `json:"receiver_id" binding:"required"`
+	ReceiverName  string `json:"receiver_name" binding:"required"`
+}
+
+func (ChatRequestExpiredPayload) NotificationType() string { return NotificationChatRequestExpired }
+
+type ThreadReplyPayload struct {
+	RoomID     string `json:"room_id" binding:"required"`
+	MessageID  string `json:"message_id" binding:"required"`
+	ThreadID   string `json:"thread_id" binding:"required"`
+	SenderID   uint   `json:"sender_id" binding:"required"`
+	SenderName string `json:"sender_name" binding:"required"`
+}
+
+func (ThreadReplyPayload) NotificationType() string { return NotificationThreadReply }
+
+type MentionPayload struct {
+	RoomID     string `json:"room_id" binding:"required"`
+	MessageID  string `json:"message_id" binding:"required"`
+	SenderID   uint   `json:"sender_id" binding:"required"`
+	SenderName string `json:"sender_name" binding:"required"`
+}
+
+func (MentionPayload) NotificationType() string { return NotificationMention }
+
+type NewMessagePayload struct {
+	RoomID     string `json:"room_id" binding:"required"`
+	MessageID  string `json:"message_id" binding:"required"`
+	SenderID   uint   `json:"sender_id" binding:"required"`
+	SenderName string `json:"sender_name" binding:"required"`
+}
+
+func (NewMessagePayload) NotificationType() string { return NotificationNewMessage }
+
+type JoinRequestReceivedPayload struct {
+	RoomID        string `json:"room_id" binding:"required"`
+	RoomName      string `json:"room_name"`
+	JoinRequestID string `json:"join_request_id" binding:"required"`
+	UserID        uint   `json:"user_id" binding:"required"`
+	UserName      string `json:"user_name" binding:"required"`
+}
+
+func (JoinRequestReceivedPayload) NotificationType() string { return NotificationJoinRequestReceived }
+
+type JoinRequestAcceptedPayload struct {
+	RoomID   string `json:"room_id" binding:"required"`
+	RoomName string `json:"room_name"`
+}
+
+func (JoinRequestAcceptedPayload) NotificationType() string { return NotificationJoinRequestAccepted }
+
+// notificationTemplates holds the rendered text of every registered type, keyed by type then locale
+var notificationTemplates = map[string]map[string]*template.Template{}
+
+func registerNotificationType(notificationType string, translations map[string]string) {
+	if _, ok := translations[DefaultLocale]; !ok {
+		panic(fmt.Sprintf("notification type %s has no %s template", notificationType, DefaultLocale))
+	}
+
+	templates := make(map[string]*template.Template, len(translations))
+	for locale, text := range translations {
+		templates[locale] = template.Must(template.New(notificationType + "." + locale).Parse(text))
+	}
+	notificationTemplates[notificationType] = templates
+}
+
+func init() {
+	registerNotificationType(NotificationChatRequestReceived, map[string]string{
+		LocaleEnglish: "{{.SenderName}} sent you a chat request.",
+		LocaleSpanish: "{{.SenderName}} te envió una solicitud de chat.",
+		LocaleFrench:  "{{.SenderName}} vous a envoyé une demande de discussion.",
+	})
+	registerNotificationType(NotificationChatRequestAccepted, map[string]string{
+		LocaleEnglish: "Your chat request to {{.ReceiverName}} has been accepted.",
+		LocaleSpanish: "{{.ReceiverName}} aceptó tu solicitud de chat.",
+		LocaleFrench:  "{{.ReceiverName}} a accepté votre demande de discussion.",
+	})
+	registerNotificationType(NotificationChatRequestRejected, map[string]string{
+		LocaleEnglish: "{{.ReceiverName}} declined your chat request.",
+		LocaleSpanish: "{{.ReceiverName}} rechazó tu solicitud de chat.",
+		LocaleFrench:  "{{.ReceiverName}} a refusé votre demande de discussion.",
+	})
+	registerNotificationType(NotificationChatRequestCancelled, map[string]string{
+		LocaleEnglish: "{{.SenderName}} withdrew their chat request.",
+		LocaleSpanish: "{{.SenderName}} retiró su solicitud de chat.",
+		LocaleFrench:  "{{.SenderName}} a retiré sa demande de discussion.",
+	})
+	registerNotificationType(NotificationChatRequestExpired, map[string]string{
+		LocaleEnglish: "Your chat request to {{.ReceiverName}} expired without an answer.",
+		LocaleSpanish: "Tu solicitud de chat a {{.ReceiverName}} expiró sin respuesta.",
+		LocaleFrench:  "Votre demande de discussion à {{.ReceiverName}} a expiré sans réponse.",
+	})
+	registerNotificationType(NotificationThreadReply, map[string]string{
+		LocaleEnglish: "{{.SenderName}} replied in a thread you are part of.",
+		LocaleSpanish: "{{.SenderName}} respondió en un hilo en el que participas.",
+		LocaleFrench:  "{{.SenderName}} a répondu dans un fil auquel vous participez.",
+	})
+	registerNotificationType(NotificationMention, map[string]string{
+		LocaleEnglish: "{{.SenderName}} mentioned you in a message.",
+		LocaleSpanish: "{{.SenderName}} te mencionó en un mensaje.",
+		LocaleFrench:  "{{.SenderName}} vous a mentionné dans un message.",
+	})
+	registerNotificationType(NotificationNewMessage, map[string]string{
+		LocaleEnglish: "New message from {{.SenderName}}.",
+		LocaleSpanish: "Nuevo mensaje de {{.SenderName}}.",
+		LocaleFrench:  "Nouveau message de {{.SenderName}}.",
+	})
+	registerNotificationType(NotificationJoinRequestReceived, map[string]string{
+		LocaleEnglish: "{{.UserName}} asked to join {{.RoomName}}.",
+		LocaleSpanish: "{{.UserName}} pidió unirse a {{.RoomName}}.",
+		LocaleFrench:  "{{.UserName}} a demandé à rejoindre {{.RoomName}}.",
+	})
+	registerNotificationType(NotificationJoinRequestAccepted, map[string]string{
+		LocaleEnglish: "Your request to join {{.RoomName}} has been accepted.",
+		LocaleSpanish: "Tu solicitud para unirte a {{.RoomName}} fue aceptada.",
+		LocaleFrench:  "Votre demande pour rejoindre {{.RoomName}} a été acceptée.",
+	})
+}
+
+// IsSupportedLocale reports whether notifications can be rendered in locale
+func IsSupportedLocale(locale string) bool {
+	switch locale {
+	case LocaleEnglish, LocaleSpanish, LocaleFrench:
+		return true
+	}
+	return false
+}
+
+// RenderNotification renders the text of a payload in locale, falling back to DefaultLocale
+func RenderNotification(payload NotificationPayload, locale string) (string, error) {
+	templates, ok := notificationTemplates[payload.NotificationType()]
+	if !ok {
+		return "", fmt.Errorf("unknown notification type %q", payload.NotificationType())
+	}
+
+	tmpl, ok := templates[locale]
+	if !ok {
+		tmpl = templates[DefaultLocale]
+	}
+
+	var text strings.Builder
+	if err := tmpl.Execute(&text, payload); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notification types
+const (
+	NotificationChatRequestReceived  = "chat_request_received"
+	NotificationChatRequestAccepted  = "chat_request_accepted"
+	NotificationChatRequestRejected  = "chat_request_rejected"
+	NotificationChatRequestCancelled = "chat_request_cancelled"
+	NotificationChatRequestExpired   = "chat_request_expired"
+	NotificationThreadReply          = "thread_reply"
+	NotificationMention              = "mention"
+	NotificationNewMessage           = "new_message"
+	NotificationJoinRequestReceived  = "join_request_received"
+	NotificationJoinRequestAccepted  = "join_request_accepted"
+)
+
+// CreateNotification creates the notification in its own transaction, see CreateNotificationTx
+func CreateNotification(userID uint, payload NotificationPayload) (*models.Notification, error) {
+	var notification *models.Notification
+	err := OutboxTransaction(func(tx *gorm.DB) error {
+		var err error
+		notification, err = CreateNotificationTx(tx, userID, payload)
+		return err
+	})
+	return notification, err
+}
+
+// CreateNotificationTx validates the payload, renders it in the user's locale, stores the
+// notification in tx and queues its delivery on the channels the user's preferences allow, so
+// it is only sent if tx commits. Aggregated types are folded into a recent unread notification
+// of the same group instead of adding a row.
+// It returns nil without an error when the user turned every channel off for the type.
+func CreateNotificationTx(tx *gorm.DB, userID uint, payload NotificationPayload) (*models.Notification, error) {
+	draft, err := buildNotification(userID, payload)
+	if err != nil || draft == nil {
+		return nil, err
+	}
+	notification := draft.notification
+
+	if aggregation, group := notificationGroup(payload); aggregation != nil {
+		notification.GroupKey = &group
+		notification.ActorIDs = []uint{aggregation.actor(payload)}
+
+		aggregated, err := aggregateNotification(tx, draft, payload, aggregation)
+		if err != nil || aggregated != nil {
+			return aggregated, err
+		}
+	}
+
+	if err := tx.Create(notification).Error; err != nil {
+		return nil, err
+	}
+
+	if err := enqueueNotificationDelivery(tx, *notification, draft.delivery, "notification.created"); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// aggregateNotification adds the event to the user's open notification of the same group created
+// within the aggregation window, returning nil when there is none to add to
+func aggregateNotification(tx *gorm.DB, draft *notificationDraft, payload NotificationPayload, aggregation *notificationAggregation) (*models.Notification, error) {
+	notification := draft.notification
+	now := GetCurrentTimestamp()
+
+	var existing models.Notification
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND group_key = ? AND hidden = ? AND read_at IS NULL AND resolved_at IS NULL AND created_at > ?",
+			notification.UserID, *notification.GroupKey, notification.Hidden, now.Add(-aggregation.window)).
+		Order("created_at DESC").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Count++
+	existing.ActorIDs = addActor(existing.ActorIDs, aggregation.actor(payload))
+	message, err := aggregation.render(payload, draft.user.Locale, existing.Count, existing.ActorIDs)
+	if err != nil {
+		return nil, err
+	}
+	existing.Message = message
+	existing.Payload = notification.Payload
+	if draft.delivery.Email {
+		// Let the next digest pick up the new count
+		existing.EmailedAt = nil
+	}
+	if err := tx.Save(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	if err := enqueueNotificationDelivery(tx, existing, draft.delivery, "notification.updated"); err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// UpsertNotification refreshes the user's latest notification with dedupKey in tx if it was created
+// within window, reopening it and marking it unread, and only creates a new one otherwise
+func UpsertNotification(tx *gorm.DB, userID uint, dedupKey string, window time.Duration, payload NotificationPayload) (*models.Notification, error) {
+	return upsertNotification(tx, userID, dedupKey, window, payload, nil)
+}
+
+// upsertNotification is UpsertNotification storing the notification as resolved at resolvedAt,
+// for notices that need no answer
+func upsertNotification(tx *gorm.DB, userID uint, dedupKey string, window time.Duration, payload NotificationPayload, resolvedAt *time.Time) (*models.Notification, error) {
+	draft, err := buildNotification(userID, payload)
+	if err != nil || draft == nil {
+		return nil, err
+	}
+	notification, delivery := draft.notification, draft.delivery
+	notification.ResolvedAt = resolvedAt
+
+	var existing models.Notification
+	err = tx.Where("user_id = ? AND dedup_key = ? AND created_at > ?", userID, dedupKey, GetCurrentTimestamp().Add(-window)).
+		Order("created_at DESC").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		notification.DedupKey = &dedupKey
+		if err := tx.Create(notification).Error; err != nil {
+			return nil, err
+		}
+		if err := enqueueNotificationDelivery(tx, *notification, delivery, "notification.created"); err != nil {
+			return nil, err
+		}
+		return notification, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Type = notification.Type
+	existing.Message = notification.Message
+	existing.Payload = notification.Payload
+	existing.ResolvedAt = resolvedAt
+	existing.ReadAt = nil
+	existing.Hidden = notification.Hidden
+	if err := tx.Save(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	// Refreshing doesn't push again, that is the point of deduplicating
+	if delivery.InApp {
+		if err := EnqueueUserEvent(tx, userID, Event{Type: "notification.updated", Data: existing}); err != nil {
+			return nil, err
+		}
+	}
+	return &existing, nil
+}
+
+// ResolveNotifications marks the user's open notifications with dedupKey as resolved and read in tx
+func ResolveNotifications(tx *gorm.DB, userID uint, dedupKey string) error {
+	var ids []uint
+	query := tx.Model(&models.Notification{}).Where("user_id = ? AND dedup_key = ? AND resolved_at IS NULL", userID, dedupKey)
+	if err := query.Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return err
+	}
+
+	now := GetCurrentTimestamp()
+	err := tx.Model(&models.Notification{}).Where("id IN ?", ids).Updates(map[string]any{
+		"resolved_at": now,
+		"read_at":     gorm.Expr("COALESCE(read_at, ?)", now),
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return EnqueueUserEvent(tx, userID, Event{Type: "notification.resolved", Data: map[string]any{"ids": ids}})
+}
+
+// notificationDraft is a notification ready to be stored, with its recipient and channels
+type notificationDraft struct {
+	notification *models.Notification
+	user         models.User
+	delivery     NotificationDelivery
+}
+
+// buildNotification validates the payload, renders it in the user's locale and works out its
+// channels. The draft is nil when no channel is left.
+func buildNotification(userID uint, payload NotificationPayload) (*notificationDraft, error) {
+	if err := binding.Validator.ValidateStruct(payload); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	delivery, err := NotificationDeliveryFor(user, payload.NotificationType(), GetCurrentTimestamp())
+	if err != nil || !delivery.Any() {
+		return nil, err
+	}
+
+	message, err := RenderNotification(payload, user.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := &models.Notification{
+		UserID:  userID,
+		Type:    payload.NotificationType(),
+		Message: message,
+		Payload: encoded,
+		Count:   1,
+		// The row is kept for the other channels but stays out of the in-app list
+		Hidden: !delivery.InApp,
+	}
+	if !delivery.Email {
+		now := GetCurrentTimestamp()
+		notification.EmailedAt = &now
+	}
+	return &notificationDraft{notification: notification, user: user, delivery: delivery}, nil
+}
+
+// enqueueNotificationDelivery queues the real-time event and pushes of a notification in tx.
+// Email needs nothing here, digests pick up notifications that haven't been emailed.
+func enqueueNotificationDelivery(tx *gorm.DB, notification models.Notification, delivery NotificationDelivery, eventType string) error {
+	if delivery.InApp {
+		if err := EnqueueUserEvent(tx, notification.UserID, Event{Type: eventType, Data: notification}); err != nil {
+			return err
+		}
+	}
+	if delivery.Push {
+		return EnqueuePush(tx, notification)
+	}
+	return nil
+}
+
+// RoomNotificationRecipients filters users down to those whose room settings allow a notification.
+// Mention level notifications (mentions, thread replies) pass the "mentions" level, everything
+// else needs "all". Muted members get nothing until the mute expires.
+func RoomNotificationRecipients(roomID string, userIDs []uint, mentionLevel bool) []uint {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var members []models.RoomMember
+	config.DB.Where("room_id = ? AND user_id IN ?", roomID, userIDs).Find(&members)
+
+	now := GetCurrentTimestamp()
+	var recipients []uint
+	for _, member := range members {
+		if member.IsMuted(now) {
+			continue
+		}
+		switch member.NotificationLevel {
+		case models.NotifyNone:
+			continue
+		case models.NotifyMentions:
+			if !mentionLevel {
+				continue
+			}
+		}
+		recipients = append(recipients, member.UserID)
+	}
+	return recipients
+}
+
+// UnreadNotificationCount returns the number of unread notifications of the user
+func UnreadNotificationCount(userID uint) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL AND NOT hidden", userID).Count(&count).Error
+	return count, err
+}
+
+// MigrateNotificationReadAt carries the old read flag over to read_at and drops it
+func MigrateNotificationReadAt(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Notification{}, "read") {
+		return nil
+	}
+
+	if err := db.Exec("UPDATE notifications SET read_at = updated_at WHERE read AND read_at IS NULL").Error; err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&models.Notification{}, "read")
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"gorm.io/gorm"
+)
+
+// Outbox topics
+const (
+	OutboxTopicEvent   = "event"
+	OutboxTopicEmail   = "email"
+	OutboxTopicPush    = "push"
+	OutboxTopicWebhook = "webhook"
+)
+
+const (
+	outboxBatchSize   = 50
+	outboxMaxAttempts = 10
+	// outboxLease is how long a claimed entry belongs to the dispatcher before another run may take it over
+	outboxLease = 5 * time.Minute
+)
+
+// OutboxHandler delivers one outbox entry, an error schedules a retry
+type OutboxHandler func(ctx context.Context, entry models.OutboxEntry) error
+
+var outboxHandlers = map[string]OutboxHandler{}
+
+// outboxWake lets committed transactions start a dispatch without waiting for the next tick,
+// there is one channel per topic
+var outboxWake = map[string]chan struct{}{}
+
+// RegisterOutboxHandler sets the handler of a topic
+func RegisterOutboxHandler(topic string, handler OutboxHandler) {
+	outboxHandlers[topic] = handler
+	outboxWake[topic] = make(chan struct{}, 1)
+}
+
+// EnqueueOutbox records a side effect in tx, it is delivered once tx commits
+func EnqueueOutbox(tx *gorm.DB, topic string, payload any) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxEntry{
+		Topic:         topic,
+		Payload:       encoded,
+		Status:        models.OutboxPending,
+		NextAttemptAt: GetCurrentTimestamp(),
+	}).Error
+}
+
+// OutboxTransaction runs fn in a transaction and wakes the dispatcher once it commits
+func OutboxTransaction(fn func(tx *gorm.DB) error) error {
+	if err := config.DB.Transaction(fn); err != nil {
+		return err
+	}
+	WakeOutbox()
+	return nil
+}
+
+// WakeOutbox asks the dispatcher of every topic to look for due entries now
+func WakeOutbox() {
+	for _, wake := range outboxWake {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// outboxBackoff is the delay before the next attempt, doubling from 5 seconds up to an hour
+func outboxBackoff(attempts int) time.Duration {
+	delay := 5 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, time.Hour)
+}
+
+// DispatchOutbox claims a batch of due entries of the topic and delivers them, returning how many
+// were claimed. Entries are claimed with SKIP LOCKED so several app instances can dispatch side by side;
+// real-time events reach the connections of every instance through the event relay.
+func DispatchOutbox(topic string) (int, error) {
+	now := GetCurrentTimestamp()
+
+	var entries []models.OutboxEntry
+	err := config.DB.Raw(`UPDATE outbox_entries SET status = ?, attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_entries
+			WHERE topic = ? AND status IN (?, ?) AND next_attempt_at <= ?
+			ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED
+		) RETURNING *`,
+		models.OutboxProcessing, now.Add(outboxLease), now,
+		topic, models.OutboxPending, models.OutboxProcessing, now, outboxBatchSize).
+		Scan(&entries).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		deliverOutboxEntry(entry)
+	}
+	return len(entries), nil
+}
+
+func deliverOutboxEntry(entry models.OutboxEntry) {
+	err := fmt.Errorf("no handler for outbox topic %q", entry.Topic)
+	if handler, ok := outboxHandlers[entry.Topic]; ok {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err = handler(ctx, entry)
+		cancel()
+	}
+
+	now := GetCurrentTimestamp()
+	updates := map[string]any{"updated_at": now}
+	switch {
+	case err == nil:
+		updates["status"] = models.OutboxDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case entry.Attempts >= outboxMaxAttempts:
+		log.Printf("Outbox entry %d (%s) failed for good: %v", entry.ID, entry.Topic, err)
+		updates["status"] = models.OutboxDead
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = models.OutboxPending
+		updates["next_attempt_at"] = now.Add(outboxBackoff(entry.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := config.DB.Model(&models.OutboxEntry{}).Where("id = ?", entry.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update outbox entry %d: %v", entry.ID, err)
+	}
+}
+
+// RetryOutboxEntry puts a dead entry back in the queue with a fresh set of attempts
+func RetryOutboxEntry(id uint) (bool, error) {
+	result := config.DB.Model(&models.OutboxEntry{}).Where("id = ? AND status = ?", id, models.OutboxDead).Updates(map[string]any{
+		"status":          models.OutboxPending,
+		"attempts":        0,
+		"next_attempt_at": GetCurrentTimestamp(),
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	WakeOutbox()
+	return true, nil
+}
+
+// PurgeDeliveredOutbox deletes entries delivered more than a week ago
+func PurgeDeliveredOutbox() (int64, error) {
+	result := config.DB.Where("status = ? AND delivered_at < ?", models.OutboxDelivered, GetCurrentTimestamp().AddDate(0, 0, -7)).
+		Delete(&models.OutboxEntry{})
+	return result.RowsAffected, result.Error
+}
+
+// StartOutboxDispatcher delivers outbox entries in the background, every interval or as soon as
+// a transaction with new entries commits. Each topic has its own worker, so slow webhook or email
+// deliveries never hold back real-time events.
+func StartOutboxDispatcher(interval time.Duration) {
+	for topic, wake := range outboxWake {
+		go dispatchOutboxTopic(topic, wake, interval)
+	}
+}
+
+func dispatchOutboxTopic(topic string, wake <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		claimed, err := DispatchOutbox(topic)
+		if err != nil {
+			log.Printf("Failed to dispatch %s outbox: %v", topic, err)
+		}
+		// A full batch means there is probably more waiting
+		if claimed == outboxBatchSize {
+			continue
+		}
+
+		select {
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// outboxEvent is a real-time event for the users or room it is addressed to
+type outboxEvent struct {
+	UserID uint   `json:"user_id,omitempty"`
+	RoomID string `json:"room_id,omitempty"`
+	Event  Event  `json:"event"`
+}
+
+// EnqueueUserEvent records a real-time event for a user's connections in tx
+func EnqueueUserEvent(tx *gorm.DB, userID uint, event Event) error {
+	return EnqueueOutbox(tx, OutboxTopicEvent, outboxEvent{UserID: userID, Event: event})
+}
+
+// EnqueueRoomEvent records a real-time event for every member of a room in tx
+func EnqueueRoomEvent(tx *gorm.DB, roomID string, event Event) error {
+	return EnqueueOutbox(tx, OutboxTopicEvent, outboxEvent{RoomID: roomID, Event: event})
+}
+
+func init() {
+	// Whichever instance claims the entry announces it to all of them, see StartEventRelay
+	RegisterOutboxHandler(OutboxTopicEvent, func(ctx context.Context, entry models.OutboxEntry) error {
+		return config.DB.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", eventsChannel, strconv.FormatUint(uint64(entry.ID), 10)).Error
+	})
+}
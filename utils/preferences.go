@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"sort"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+)
+
+// Notification delivery channels
+const (
+	ChannelInApp = "in_app"
+	ChannelEmail = "email"
+	ChannelPush  = "push"
+)
+
+var NotificationChannels = []string{ChannelInApp, ChannelEmail, ChannelPush}
+
+// NotificationDelivery is where a notification goes once preferences and quiet hours are applied
+type NotificationDelivery struct {
+	InApp bool
+	Email bool
+	Push  bool
+}
+
+func (d NotificationDelivery) Any() bool {
+	return d.InApp || d.Email || d.Push
+}
+
+// NotificationTypes lists every registered notification type
+func NotificationTypes() []string {
+	types := make([]string, 0, len(notificationTemplates))
+	for notificationType := range notificationTemplates {
+		types = append(types, notificationType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// IsNotificationType reports whether notificationType is registered
+func IsNotificationType(notificationType string) bool {
+	_, ok := notificationTemplates[notificationType]
+	return ok
+}
+
+// NotificationDeliveryFor applies the user's preferences for the type. Push is held back during
+// quiet hours, email digests check quiet hours themselves when they are sent. Bots get nothing.
+func NotificationDeliveryFor(user models.User, notificationType string, now time.Time) (NotificationDelivery, error) {
+	// Bots follow their rooms through their webhook
+	if user.IsBot {
+		return NotificationDelivery{}, nil
+	}
+
+	delivery := NotificationDelivery{InApp: true, Email: true, Push: true}
+
+	var preferences []models.NotificationPreference
+	if err := config.DB.Where("user_id = ? AND type = ?", user.ID, notificationType).Find(&preferences).Error; err != nil {
+		return delivery, err
+	}
+	for _, preference := range preferences {
+		switch preference.Channel {
+		case ChannelInApp:
+			delivery.InApp = preference.Enabled
+		case ChannelEmail:
+			delivery.Email = preference.Enabled
+		case ChannelPush:
+			delivery.Push = preference.Enabled
+		}
+	}
+
+	if delivery.Push && InQuietHours(user, now) {
+		delivery.Push = false
+	}
+	return delivery, nil
+}
+
+// InQuietHours reports whether now falls in the user's quiet hours, which may span midnight
+func InQuietHours(user models.User, now time.Time) bool {
+	if user.QuietHoursStart == nil || user.QuietHoursEnd == nil {
+		return false
+	}
+
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	start, err := time.Parse("15:04", *user.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", *user.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+)
+
+// TypingTimeout is how long a typing indicator lasts without being refreshed
+const TypingTimeout = 8 * time.Second
+
+// UserConnected announces a user coming online to everyone they share a room with.
+// Call it only when Hub.Subscribe reports the user's first connection.
+func UserConnected(userID uint) {
+	publishToContacts(userID, Event{
+		Type: "presence.online",
+		Data: models.UserPresence{UserID: userID, Online: true},
+	})
+}
+
+// UserDisconnected records last_seen_at once the user's last connection closes.
+// Call it only when Hub.Unsubscribe reports the user's last connection.
+func UserDisconnected(userID uint) {
+	lastSeenAt := GetCurrentTimestamp()
+	if err := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("last_seen_at", lastSeenAt).Error; err != nil {
+		log.Printf("Failed to update last seen for user %d: %v", userID, err)
+	}
+
+	presence := models.UserPresence{UserID: userID, Online: false}
+	var user models.User
+	if err := config.DB.Select("hide_last_seen").First(&user, userID).Error; err == nil && !user.HideLastSeen {
+		presence.LastSeenAt = &lastSeenAt
+	}
+
+	// A reconnect may have slipped in while last_seen_at was being written
+	if Hub.ConnectionCount(userID) > 0 {
+		return
+	}
+	publishToContacts(userID, Event{Type: "presence.offline", Data: presence})
+}
+
+// GetPresence reports online status for the given users as seen by viewerID.
+// Users who share no room with the viewer are left out, and so is the last seen time of users who hide it.
+func GetPresence(viewerID uint, userIDs []uint) ([]models.UserPresence, error) {
+	var users []models.User
+	err := config.DB.Select("id", "last_seen_at", "hide_last_seen").
+		Where("id IN ?", userIDs).
+		Where("id = ? OR id IN (?)", viewerID,
+			config.DB.Table("room_members").Select("user_id").
+				Where("room_id IN (?)", config.DB.Table("room_members").Select("room_id").Where("user_id = ?", viewerID))).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	presences := make([]models.UserPresence, 0, len(users))
+	for _, user := range users {
+		presence := models.UserPresence{
+			UserID: user.ID,
+			Online: Hub.ConnectionCount(user.ID) > 0,
+		}
+		if !user.HideLastSeen || user.ID == viewerID {
+			presence.LastSeenAt = user.LastSeenAt
+		}
+		presences = append(presences, presence)
+	}
+	return presences, nil
+}
+
+// GetContactIDs returns every user who shares at least one room with the user
+func GetContactIDs(userID uint) []uint {
+	var contactIDs []uint
+	config.DB.Table("room_members").
+		Where("room_id IN (?) AND user_id <> ?",
+			config.DB.Table("room_members").Select("room_id").Where("user_id = ?", userID), userID).
+		Distinct().
+		Pluck("user_id", &contactIDs)
+	return contactIDs
+}
+
+func publishToContacts(userID uint, event Event) {
+	for _, contactID := range GetContactIDs(userID) {
+		Hub.PublishToUser(contactID, event)
+	}
+}
+
+// TypingTracker expires typing indicators that were never explicitly stopped
+type TypingTracker struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+var Typing = &TypingTracker{timers: make(map[string]*time.Timer)}
+
+// Start publishes typing.started and (re)arms the automatic stop
+func (t *TypingTracker) Start(roomID string, userID uint) {
+	key := fmt.Sprintf("%s:%d", roomID, userID)
+
+	t.mu.Lock()
+	previous, alreadyTyping := t.timers[key]
+	if alreadyTyping {
+		previous.Stop()
+	}
+	// A fresh timer per refresh, so an expiry that already fired cannot end the refreshed indicator
+	var timer *time.Timer
+	timer = time.AfterFunc(TypingTimeout, func() {
+		t.stop(roomID, userID, timer)
+	})
+	t.timers[key] = timer
+	t.mu.Unlock()
+
+	if !alreadyTyping {
+		Hub.PublishToRoom(roomID, Event{Type: "typing.started", Data: map[string]any{"user_id": userID}})
+	}
+}
+
+// Stop publishes typing.stopped if the user was typing in the room
+func (t *TypingTracker) Stop(roomID string, userID uint) {
+	t.stop(roomID, userID, nil)
+}
+
+// stop ends the indicator; with expected set, only if that timer is still the one armed for it
+func (t *TypingTracker) stop(roomID string, userID uint, expected *time.Timer) {
+	key := fmt.Sprintf("%s:%d", roomID, userID)
+
+	t.mu.Lock()
+	timer, typing := t.timers[key]
+	if typing && expected != nil && timer != expected {
+		typing = false
+	}
+	if typing {
+		timer.Stop()
+		delete(t.timers, key)
+	}
+	t.mu.Unlock()
+
+	if typing {
+		Hub.PublishToRoom(roomID, Event{Type: "typing.stopped", Data: map[string]any{"user_id": userID}})
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+	"gin-project/webpush"
+
+	"gorm.io/gorm"
+)
+
+// pushTTL is how long push services keep a notification for a device that is offline
+const pushTTL = 24 * time.Hour
+
+type pushMessage struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	Message   string          `json:"message"`
+	Payload   json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// outboxPush is one notification for one device, so a retry only resends to the device that failed
+type outboxPush struct {
+	NotificationID uint `json:"notification_id"`
+	SubscriptionID uint `json:"subscription_id"`
+}
+
+// EnqueuePush records in tx a push of the notification to every subscription of its user
+func EnqueuePush(tx *gorm.DB, notification models.Notification) error {
+	if config.Push == nil {
+		return nil
+	}
+
+	var subscriptionIDs []uint
+	if err := tx.Model(&models.PushSubscription{}).Where("user_id = ?", notification.UserID).Pluck("id", &subscriptionIDs).Error; err != nil {
+		return err
+	}
+
+	for _, subscriptionID := range subscriptionIDs {
+		if err := EnqueueOutbox(tx, OutboxTopicPush, outboxPush{NotificationID: notification.ID, SubscriptionID: subscriptionID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverPush sends one notification to one device. Subscriptions the push service reports as
+// gone are deleted, pushes whose notification or subscription no longer exist are dropped.
+func deliverPush(ctx context.Context, outboxEntry models.OutboxEntry) error {
+	if config.Push == nil {
+		return errors.New("web push is not configured")
+	}
+
+	var entry outboxPush
+	if err := json.Unmarshal(outboxEntry.Payload, &entry); err != nil {
+		return err
+	}
+
+	var notification models.Notification
+	var subscription models.PushSubscription
+	if err := config.DB.First(&notification, entry.NotificationID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := config.DB.First(&subscription, entry.SubscriptionID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	message := pushMessage{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		Message:   notification.Message,
+		Payload:   notification.Payload,
+		CreatedAt: notification.CreatedAt,
+	}
+	body, err := json.Marshal(message)
+	if err == nil && len(body) > webpush.MaxPayloadSize {
+		// The client can fetch the full notification by id
+		message.Payload = nil
+		body, err = json.Marshal(message)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = config.Push.Send(ctx, webpush.Subscription{
+		Endpoint: subscription.Endpoint,
+		P256dh:   subscription.P256dh,
+		Auth:     subscription.Auth,
+	}, body, pushTTL)
+	if errors.Is(err, webpush.ErrSubscriptionGone) {
+		return config.DB.Delete(&subscription).Error
+	}
+	return err
+}
+
+func init() {
+	RegisterOutboxHandler(OutboxTopicPush, deliverPush)
+}
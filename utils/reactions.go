@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"gin-project/config"
+	"gin-project/models"
+)
+
+// AttachReactions fills in the aggregated reactions of each message with a single query
+func AttachReactions(messages []*models.Message, userID uint) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]string, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+		message.Reactions = []models.ReactionSummary{}
+	}
+
+	var rows []struct {
+		MessageID   string
+		Emoji       string
+		Count       int
+		ReactedByMe bool
+	}
+	err := config.DB.Model(&models.MessageReaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted_by_me", userID).
+		Where("message_id IN ?", messageIDs).
+		Group("message_id, emoji").
+		Order("MIN(created_at)").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	byMessage := make(map[string][]models.ReactionSummary)
+	for _, row := range rows {
+		byMessage[row.MessageID] = append(byMessage[row.MessageID], models.ReactionSummary{
+			Emoji:       row.Emoji,
+			Count:       row.Count,
+			ReactedByMe: row.ReactedByMe,
+		})
+	}
+
+	for _, message := range messages {
+		if reactions, ok := byMessage[message.ID]; ok {
+			message.Reactions = reactions
+		}
+	}
+
+	return nil
+}
+
+// AttachReactionsToList is AttachReactions for a slice of message values
+func AttachReactionsToList(messages []models.Message, userID uint) error {
+	pointers := make([]*models.Message, len(messages))
+	for i := range messages {
+		pointers[i] = &messages[i]
+	}
+	return AttachReactions(pointers, userID)
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// retentionBatchSize keeps each delete short so it doesn't hold locks on a large table for long
+const retentionBatchSize = 1000
+
+// retentionDays reads a day count from the environment, 0 keeps notifications forever
+func retentionDays(name string, fallback int) int {
+	days, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || days < 0 {
+		return fallback
+	}
+	return days
+}
+
+// PurgeNotifications deletes read notifications older than NOTIFICATION_RETENTION_READ_DAYS and
+// unread ones older than NOTIFICATION_RETENTION_UNREAD_DAYS, returning how many rows were removed
+func PurgeNotifications() (int64, error) {
+	now := GetCurrentTimestamp()
+	var purged int64
+
+	if days := retentionDays("NOTIFICATION_RETENTION_READ_DAYS", 30); days > 0 {
+		deleted, err := purgeNotificationsWhere("read_at IS NOT NULL AND read_at < ?", now.AddDate(0, 0, -days))
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	if days := retentionDays("NOTIFICATION_RETENTION_UNREAD_DAYS", 90); days > 0 {
+		deleted, err := purgeNotificationsWhere("read_at IS NULL AND created_at < ?", now.AddDate(0, 0, -days))
+		purged += deleted
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeNotificationsWhere hard deletes matching notifications in batches
+func purgeNotificationsWhere(condition string, cutoff time.Time) (int64, error) {
+	var purged int64
+	for {
+		batch := config.DB.Unscoped().Model(&models.Notification{}).Select("id").Where(condition, cutoff).Limit(retentionBatchSize)
+		result := config.DB.Unscoped().Where("id IN (?)", batch).Delete(&models.Notification{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// PurgeUnsentAttachments deletes uploads that were never sent in a message within
+// ATTACHMENT_RETENTION_UNSENT_DAYS, along with their files, returning how many were removed
+func PurgeUnsentAttachments(ctx context.Context) (int64, error) {
+	days := retentionDays("ATTACHMENT_RETENTION_UNSENT_DAYS", 1)
+	if days == 0 {
+		return 0, nil
+	}
+	cutoff := GetCurrentTimestamp().AddDate(0, 0, -days)
+
+	var purged int64
+	for {
+		// RETURNING only yields the rows really deleted, an upload claimed by a send in the meantime keeps its files
+		var attachments []models.Attachment
+		batch := config.DB.Model(&models.Attachment{}).Select("id").
+			Where("message_id IS NULL AND created_at < ?", cutoff).Limit(retentionBatchSize)
+		result := config.DB.Clauses(clause.Returning{}).
+			Where("id IN (?) AND message_id IS NULL", batch).
+			Delete(&attachments)
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		DeleteAttachmentFiles(ctx, attachments)
+		purged += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// StartNotificationRetention runs the notification, outbox, webhook delivery and unsent attachment purges every
+// interval in the background
+func StartNotificationRetention(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := PurgeNotifications()
+			if err != nil {
+				log.Printf("Failed to purge notifications: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d old notifications", purged)
+			}
+
+			if purged, err := PurgeDeliveredOutbox(); err != nil {
+				log.Printf("Failed to purge delivered outbox entries: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d delivered outbox entries", purged)
+			}
+
+			if purged, err := PurgeWebhookDeliveries(); err != nil {
+				log.Printf("Failed to purge webhook deliveries: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d webhook delivery logs", purged)
+			}
+
+			if purged, err := PurgeUnsentAttachments(context.Background()); err != nil {
+				log.Printf("Failed to purge unsent attachments: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d unsent attachments", purged)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -1,10 +1,16 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"time"
 
 	"gin-project/config"
 	"gin-project/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func CheckRoomExists(userID1, userID2 any) models.RoomCheckResult {
@@ -27,14 +33,15 @@ func CheckRoomExists(userID1, userID2 any) models.RoomCheckResult {
 	}
 }
 
-func CreateRoomBetweenUsers(currentUserID any, targetUserID any) models.RoomCreateResult {
+// CreateRoomBetweenUsers creates the direct room of two users in tx, or returns the existing one
+func CreateRoomBetweenUsers(tx *gorm.DB, currentUserID any, targetUserID any) models.RoomCreateResult {
 	roomID := fmt.Sprintf("%v_%v", currentUserID, targetUserID)
 
 	existingRoom := CheckRoomExists(currentUserID, targetUserID)
 	if existingRoom.Exists {
 		// Get the actual room object
 		var room models.Room
-		if err := config.DB.Where("id = ?", existingRoom.RoomID).First(&room).Error; err != nil {
+		if err := tx.Where("id = ?", existingRoom.RoomID).First(&room).Error; err != nil {
 			return models.RoomCreateResult{
 				Success: false,
 				Error:   "Failed to retrieve existing room",
@@ -50,7 +57,7 @@ func CreateRoomBetweenUsers(currentUserID any, targetUserID any) models.RoomCrea
 
 	// Get both users
 	var currentUser, targetUser models.User
-	if err := config.DB.First(&currentUser, currentUserID).Error; err != nil {
+	if err := tx.First(&currentUser, currentUserID).Error; err != nil {
 		return models.RoomCreateResult{
 			Success: false,
 			Error:   "Current user not found",
@@ -58,7 +65,7 @@ func CreateRoomBetweenUsers(currentUserID any, targetUserID any) models.RoomCrea
 		}
 	}
 
-	if err := config.DB.First(&targetUser, targetUserID).Error; err != nil {
+	if err := tx.First(&targetUser, targetUserID).Error; err != nil {
 		return models.RoomCreateResult{
 			Success: false,
 			Error:   "Target user not found",
@@ -72,7 +79,7 @@ func CreateRoomBetweenUsers(currentUserID any, targetUserID any) models.RoomCrea
 		Members: []models.User{currentUser, targetUser},
 	}
 
-	if err := config.DB.Create(&room).Error; err != nil {
+	if err := tx.Create(&room).Error; err != nil {
 		return models.RoomCreateResult{
 			Success: false,
 			Error:   "Failed to create room",
@@ -86,3 +93,174 @@ func CreateRoomBetweenUsers(currentUserID any, targetUserID any) models.RoomCrea
 		Room:    &room,
 	}
 }
+
+func IsRoomMember(roomID string, userID uint) bool {
+	var count int64
+	config.DB.Table("room_members").Where("room_id = ? AND user_id = ?", roomID, userID).Count(&count)
+	return count > 0
+}
+
+func GetRoomMemberIDs(roomID string) []uint {
+	var memberIDs []uint
+	config.DB.Table("room_members").Where("room_id = ?", roomID).Pluck("user_id", &memberIDs)
+	return memberIDs
+}
+
+// GetUnreadCounts counts messages from other members newer than the user's read pointer, for each room
+func GetUnreadCounts(userID uint, roomIDs []string) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	if len(roomIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		RoomID      string
+		UnreadCount int64
+	}
+	err := config.DB.Table("room_members AS rm").
+		Select("rm.room_id, COUNT(m.id) AS unread_count").
+		Joins(`LEFT JOIN messages AS m ON m.room_id = rm.room_id
+			AND m.deleted_at IS NULL
+			AND m.sender_id <> rm.user_id
+			AND (rm.last_read_at IS NULL OR m.created_at > rm.last_read_at)
+			AND (rm.cleared_at IS NULL OR m.created_at > rm.cleared_at)`).
+		Where("rm.user_id = ? AND rm.room_id IN ?", userID, roomIDs).
+		Group("rm.room_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.RoomID] = row.UnreadCount
+	}
+	return counts, nil
+}
+
+// GetRoomMember loads the room together with the user's membership in it
+func GetRoomMember(roomID string, userID uint) (*models.Room, *models.RoomMember, error) {
+	var room models.Room
+	if err := config.DB.First(&room, "id = ?", roomID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var member models.RoomMember
+	if err := config.DB.First(&member, "room_id = ? AND user_id = ?", roomID, userID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &room, &member, nil
+}
+
+// HistoryClearedAt returns when the user deleted the room's conversation for themselves, if ever.
+// Messages up to that point are hidden from them.
+func HistoryClearedAt(roomID string, userID uint) *time.Time {
+	var member models.RoomMember
+	if err := config.DB.Select("cleared_at").First(&member, "room_id = ? AND user_id = ?", roomID, userID).Error; err != nil {
+		return nil
+	}
+	return member.ClearedAt
+}
+
+// PickNextOwner chooses who inherits a group room in tx: the longest serving admin,
+// otherwise the longest serving member. Their membership stays locked until tx ends.
+func PickNextOwner(tx *gorm.DB, roomID string, excludeUserID uint) (*models.RoomMember, error) {
+	var member models.RoomMember
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_id = ? AND user_id <> ?", roomID, excludeUserID).
+		Order(fmt.Sprintf("CASE WHEN role = '%s' THEN 0 ELSE 1 END", models.RoleAdmin)).
+		Order("joined_at ASC").
+		First(&member).Error
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// DeleteRoomTx removes a room and everything in it in tx. The caller holds the room row lock, so
+// nobody can join in the meantime. The returned attachments' files are removed with
+// DeleteAttachmentFiles once tx commits, so a rollback never leaves attachments without content.
+func DeleteRoomTx(tx *gorm.DB, roomID string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	if err := tx.Where("room_id = ?", roomID).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+
+	messageIDs := tx.Unscoped().Model(&models.Message{}).Select("id").Where("room_id = ?", roomID)
+
+	if err := tx.Where("message_id IN (?)", messageIDs).Delete(&models.MessageEdit{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("message_id IN (?)", messageIDs).Delete(&models.MessageReaction{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&models.PinnedMessage{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&models.Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("room_id = ?", roomID).Delete(&models.Message{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("room_id = ?", roomID).Delete(&models.JoinRequest{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("room_id = ?", roomID).Delete(&models.RoomInvite{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("id = ?", roomID).Delete(&models.Room{}).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DeleteAttachmentFiles removes the stored files of attachments whose rows are gone
+func DeleteAttachmentFiles(ctx context.Context, attachments []models.Attachment) {
+	for _, attachment := range attachments {
+		if err := config.Storage.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("Failed to delete file %s: %v", attachment.StorageKey, err)
+		}
+		if attachment.ThumbnailKey != nil {
+			if err := config.Storage.Delete(ctx, *attachment.ThumbnailKey); err != nil {
+				log.Printf("Failed to delete file %s: %v", *attachment.ThumbnailKey, err)
+			}
+		}
+	}
+}
+
+// GetRoomModeratorIDs returns the owner and admins of a group room
+func GetRoomModeratorIDs(roomID string) []uint {
+	var userIDs []uint
+	config.DB.Model(&models.RoomMember{}).
+		Where("room_id = ? AND role IN ?", roomID, []string{models.RoleOwner, models.RoleAdmin}).
+		Pluck("user_id", &userIDs)
+	return userIDs
+}
+
+// RefreshRoomLastMessage points the room back at its latest remaining message in tx when deletedID
+// was its last one, falling back to the room's creation when no message is left
+func RefreshRoomLastMessage(tx *gorm.DB, roomID, deletedID string) error {
+	return tx.Exec(`UPDATE rooms SET
+			last_message_id = (
+				SELECT id FROM messages WHERE room_id = rooms.id AND deleted_at IS NULL ORDER BY created_at DESC LIMIT 1
+			),
+			last_activity_at = COALESCE((
+				SELECT MAX(created_at) FROM messages WHERE room_id = rooms.id AND deleted_at IS NULL
+			), created_at)
+		WHERE id = ? AND last_message_id = ?`, roomID, deletedID).Error
+}
+
+// BackfillRoomActivity fills last_message_id and last_activity_at for rooms created before they existed
+func BackfillRoomActivity(db *gorm.DB) error {
+	err := db.Exec(`UPDATE rooms SET last_message_id = lm.id, last_activity_at = lm.created_at
+		FROM (
+			SELECT DISTINCT ON (room_id) room_id, id, created_at FROM messages
+			WHERE deleted_at IS NULL ORDER BY room_id, created_at DESC
+		) AS lm
+		WHERE rooms.id = lm.room_id AND rooms.last_activity_at IS NULL`).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE rooms SET last_activity_at = created_at WHERE last_activity_at IS NULL`).Error
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"html"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchLanguage is the Postgres text search configuration used for messages
+const SearchLanguage = "english"
+
+// SearchHeadlineOptions makes ts_headline mark matches with control characters instead of HTML,
+// as it doesn't escape the message content around them
+const SearchHeadlineOptions = "StartSel=\x02, StopSel=\x03, MaxFragments=2"
+
+var snippetMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// HighlightSnippet escapes a ts_headline excerpt made with SearchHeadlineOptions and turns its
+// markers into <mark> tags, so the snippet is safe to render as HTML
+func HighlightSnippet(headline string) string {
+	return snippetMarks.Replace(html.EscapeString(headline))
+}
+
+// SetupMessageSearch adds the tsvector column and GIN index used by message search.
+// The column is generated, so Postgres keeps it in sync on every insert and update.
+func SetupMessageSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (to_tsvector('` + SearchLanguage + `', coalesce(content, ''))) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector)`,
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"encoding/json"
+
+	"gin-project/models"
+
+	"gorm.io/gorm"
+)
+
+// System message events
+const (
+	SystemMessageRoomCreated   = "room_created"
+	SystemMessageRoomRenamed   = "room_renamed"
+	SystemMessageMemberJoined  = "member_joined"
+	SystemMessageMemberAdded   = "member_added"
+	SystemMessageMemberLeft    = "member_left"
+	SystemMessageMemberRemoved = "member_removed"
+	SystemMessageRoleChanged   = "member_role_changed"
+	SystemMessageOwnerChanged  = "owner_changed"
+	SystemMessagePinned        = "message_pinned"
+	SystemMessageUnpinned      = "message_unpinned"
+)
+
+// CreateSystemMessageTx records a room event in the conversation in tx.
+// The payload carries the event and its details so clients can render their own text,
+// content is a plain English fallback.
+func CreateSystemMessageTx(tx *gorm.DB, roomID string, actorID uint, event string, details map[string]any, fallback string) (*models.Message, error) {
+	payload := map[string]any{"event": event}
+	for key, value := range details {
+		payload[key] = value
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	message := models.Message{
+		RoomID:   roomID,
+		SenderID: actorID,
+		Type:     models.MessageTypeSystem,
+		Content:  fallback,
+		Payload:  encoded,
+	}
+	if err := tx.Create(&message).Error; err != nil {
+		return nil, err
+	}
+	if err := EnqueueRoomEvent(tx, roomID, Event{Type: "message.created", Data: message}); err != nil {
+		return nil, err
+	}
+	if err := EnqueueRoomWebhookEvent(tx, WebhookMessageCreated, roomID, actorID, message); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
@@ -1,7 +1,29 @@
 package utils
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
 func GetCurrentTimestamp() time.Time {
 	return time.Now().UTC()
 }
+
+// GenerateRandomToken returns a URL safe random string built from n random bytes
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IsUniqueViolation reports whether err is Postgres rejecting a row that breaks a unique constraint
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
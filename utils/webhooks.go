@@ -0,0 +1,387 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"gin-project/config"
+	"gin-project/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Webhook events
+const (
+	WebhookMessageCreated       = "message.created"
+	WebhookMessageUpdated       = "message.updated"
+	WebhookMessageDeleted       = "message.deleted"
+	WebhookChatRequestCreated   = "chat_request.created"
+	WebhookChatRequestAccepted  = "chat_request.accepted"
+	WebhookChatRequestRejected  = "chat_request.rejected"
+	WebhookChatRequestCancelled = "chat_request.cancelled"
+	WebhookChatRequestExpired   = "chat_request.expired"
+	WebhookUserRegistered       = "user.registered"
+)
+
+// webhookEvents maps every event to whether only admins may subscribe to it
+var webhookEvents = map[string]bool{
+	WebhookMessageCreated:       false,
+	WebhookMessageUpdated:       false,
+	WebhookMessageDeleted:       false,
+	WebhookChatRequestCreated:   false,
+	WebhookChatRequestAccepted:  false,
+	WebhookChatRequestRejected:  false,
+	WebhookChatRequestCancelled: false,
+	WebhookChatRequestExpired:   false,
+	WebhookUserRegistered:       true,
+}
+
+// webhookResponseLimit is how much of an endpoint's response is kept in the delivery log
+const webhookResponseLimit = 1024
+
+// ErrUnsafeWebhookURL is returned for webhook URLs that don't point at a public http(s) address
+var ErrUnsafeWebhookURL = errors.New("webhook URL must be a public http or https address")
+
+// webhookClient only connects to public addresses, checked on the resolved IP at dial time, and
+// doesn't follow redirects, so webhooks can't be used to reach the server's own network
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 5 * time.Second, Control: checkWebhookAddress}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// allowPrivateWebhooks lets webhooks reach private addresses, for local development only
+func allowPrivateWebhooks() bool {
+	allow, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"))
+	return allow
+}
+
+// nonPublicPrefixes are the special purpose ranges of the IANA registries that webhooks may not reach
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// nat64Prefix embeds an IPv4 address in its last 4 bytes, which is checked like the IPv4 address itself
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+func isPublicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	if nat64Prefix.Contains(addr) {
+		embedded := addr.As16()
+		addr = netip.AddrFrom4([4]byte(embedded[12:]))
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkWebhookAddress(network, address string, _ syscall.RawConn) error {
+	if allowPrivateWebhooks() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return ErrUnsafeWebhookURL
+	}
+	return nil
+}
+
+// ValidateWebhookURL rejects URLs that aren't http(s) or whose host resolves to a private address.
+// Delivery checks the address again when connecting, as DNS can change in between.
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrUnsafeWebhookURL
+	}
+	if allowPrivateWebhooks() {
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(context.Background(), parsed.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return ErrUnsafeWebhookURL
+	}
+	for _, address := range addresses {
+		if !isPublicIP(address.IP) {
+			return ErrUnsafeWebhookURL
+		}
+	}
+	return nil
+}
+
+// CanSubscribeWebhook reports whether the user may subscribe a webhook to event
+func CanSubscribeWebhook(user models.User, event string) bool {
+	adminOnly, ok := webhookEvents[event]
+	return ok && (!adminOnly || user.IsAdmin)
+}
+
+// webhookMaxFailures is how many failed attempts in a row disable a webhook, WEBHOOK_MAX_FAILURES defaults to 20
+func webhookMaxFailures() int {
+	failures, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_FAILURES"))
+	if err != nil || failures <= 0 {
+		failures = 20
+	}
+	return failures
+}
+
+// webhookEnvelope is the body posted to webhooks, its id stays the same across retries and redeliveries
+type webhookEnvelope struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// outboxWebhook is one event for one webhook, so a retry only resends to the endpoint that failed
+type outboxWebhook struct {
+	WebhookID uint            `json:"webhook_id"`
+	EventID   string          `json:"event_id"`
+	Event     string          `json:"event"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// EnqueueWebhookEvent records in tx a delivery of the event to the active webhooks subscribed to
+// it that belong to one of userIDs, or to admins for admin only events
+func EnqueueWebhookEvent(tx *gorm.DB, event string, userIDs []uint, data any) error {
+	return enqueueWebhookEvent(tx, event, userIDs, data)
+}
+
+// EnqueueRoomWebhookEvent is EnqueueWebhookEvent for an event seen by every member of the room.
+// A bot is left out of the events it caused itself so it can't end up replying to its own messages.
+func EnqueueRoomWebhookEvent(tx *gorm.DB, event string, roomID string, actorID uint, data any) error {
+	audience := tx.Table("room_members").Select("user_id").Where("room_id = ?", roomID).
+		Where("user_id NOT IN (?)", tx.Table("users").Select("id").Where("id = ? AND is_bot", actorID))
+	return enqueueWebhookEvent(tx, event, audience, data)
+}
+
+// enqueueWebhookEvent takes the audience as user IDs or a subquery selecting them
+func enqueueWebhookEvent(tx *gorm.DB, event string, audience any, data any) error {
+	subscribed, err := json.Marshal([]string{event})
+	if err != nil {
+		return err
+	}
+
+	// Admins only get what they are part of, except for the events that are theirs alone
+	recipients := tx.Where("webhooks.user_id IN (?)", audience)
+	if webhookEvents[event] {
+		recipients = tx.Where("users.is_admin OR webhooks.user_id IN (?)", audience)
+	}
+
+	var webhookIDs []uint
+	err = tx.Model(&models.Webhook{}).Joins("JOIN users ON users.id = webhooks.user_id").
+		Where("webhooks.active AND webhooks.events @> ?", string(subscribed)).
+		Where(recipients).
+		Pluck("webhooks.id", &webhookIDs).Error
+	if err != nil || len(webhookIDs) == 0 {
+		return err
+	}
+
+	envelope := webhookEnvelope{ID: uuid.New().String(), Event: event, CreatedAt: GetCurrentTimestamp(), Data: data}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	for _, webhookID := range webhookIDs {
+		entry := outboxWebhook{WebhookID: webhookID, EventID: envelope.ID, Event: event, Body: body}
+		if err := EnqueueOutbox(tx, OutboxTopicWebhook, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedeliverWebhook queues a logged delivery to be sent again with the same event id
+func RedeliverWebhook(delivery models.WebhookDelivery) error {
+	return OutboxTransaction(func(tx *gorm.DB) error {
+		return EnqueueOutbox(tx, OutboxTopicWebhook, outboxWebhook{
+			WebhookID: delivery.WebhookID,
+			EventID:   delivery.EventID,
+			Event:     delivery.Event,
+			Body:      delivery.Payload,
+		})
+	})
+}
+
+// SignWebhook signs "timestamp.body" with the webhook's secret, receivers recompute it to verify
+// the payload and reject old timestamps to prevent replays
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook posts one event to one webhook and logs the attempt. Events for webhooks that were
+// deleted or disabled in the meantime are dropped.
+func deliverWebhook(ctx context.Context, outboxEntry models.OutboxEntry) error {
+	var entry outboxWebhook
+	if err := json.Unmarshal(outboxEntry.Payload, &entry); err != nil {
+		return err
+	}
+
+	var webhook models.Webhook
+	if err := config.DB.First(&webhook, entry.WebhookID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !webhook.Active {
+		return nil
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		EventID:   entry.EventID,
+		Event:     entry.Event,
+		Payload:   entry.Body,
+	}
+	err := postWebhook(ctx, webhook, entry, &delivery)
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	var attempts int64
+	config.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ? AND event_id = ?", webhook.ID, entry.EventID).Count(&attempts)
+	delivery.Attempt = int(attempts) + 1
+	if err := config.DB.Create(&delivery).Error; err != nil {
+		log.Printf("Failed to log delivery to webhook %d: %v", webhook.ID, err)
+	}
+
+	recordWebhookResult(webhook, delivery.Success)
+	return err
+}
+
+func postWebhook(ctx context.Context, webhook models.Webhook, entry outboxWebhook, delivery *models.WebhookDelivery) error {
+	timestamp := strconv.FormatInt(GetCurrentTimestamp().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(entry.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gin-project-webhooks")
+	req.Header.Set("X-Webhook-ID", entry.EventID)
+	req.Header.Set("X-Webhook-Event", entry.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(webhook.Secret, timestamp, entry.Body))
+
+	start := time.Now()
+	resp, err := webhookClient.Do(req)
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	delivery.StatusCode = &resp.StatusCode
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	delivery.ResponseBody = string(response)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recordWebhookResult tracks failures in a row and disables the webhook once there are too many
+func recordWebhookResult(webhook models.Webhook, success bool) {
+	if success {
+		if webhook.ConsecutiveFailures > 0 {
+			config.DB.Model(&models.Webhook{}).Where("id = ?", webhook.ID).Update("consecutive_failures", 0)
+		}
+		return
+	}
+
+	config.DB.Model(&models.Webhook{}).Where("id = ?", webhook.ID).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1"))
+
+	result := config.DB.Model(&models.Webhook{}).
+		Where("id = ? AND active AND consecutive_failures >= ?", webhook.ID, webhookMaxFailures()).
+		Updates(map[string]any{"active": false, "disabled_at": GetCurrentTimestamp()})
+	if result.Error != nil {
+		log.Printf("Failed to disable webhook %d: %v", webhook.ID, result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Disabled webhook %d after %d failed deliveries in a row", webhook.ID, webhookMaxFailures())
+	}
+}
+
+// PurgeWebhookDeliveries deletes delivery logs older than 30 days
+func PurgeWebhookDeliveries() (int64, error) {
+	result := config.DB.Where("created_at < ?", GetCurrentTimestamp().AddDate(0, 0, -30)).Delete(&models.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+func init() {
+	RegisterOutboxHandler(OutboxTopicWebhook, deliverWebhook)
+}
+
+// WebhookEventsFor lists the events the user may subscribe webhooks to
+func WebhookEventsFor(user models.User) []string {
+	var events []string
+	for event := range webhookEvents {
+		if CanSubscribeWebhook(user, event) {
+			events = append(events, event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
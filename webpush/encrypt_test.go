@@ -0,0 +1,148 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// Test vector from RFC 8291 Appendix A
+const (
+	rfcPlaintext  = "When I grow up, I want to be a watermelon"
+	rfcASPrivate  = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPrivate  = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic   = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcSalt       = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcAuthSecret = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcMessage    = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func mustDecode(t *testing.T, key string) []byte {
+	t.Helper()
+	decoded, err := decodeKey(key)
+	if err != nil {
+		t.Fatalf("decode %q: %v", key, err)
+	}
+	return decoded
+}
+
+func TestSealMatchesRFC8291Vector(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcASPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(mustDecode(t, rfcUAPublic))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := seal(uaPublic, mustDecode(t, rfcAuthSecret), asPrivate, mustDecode(t, rfcSalt), []byte(rfcPlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if encoded := base64.RawURLEncoding.EncodeToString(got); encoded != rfcMessage {
+		t.Errorf("encrypted message\n got %s\nwant %s", encoded, rfcMessage)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	sub := Subscription{Endpoint: "https://push.example.net/x", P256dh: rfcUAPublic, Auth: rfcAuthSecret}
+
+	body, err := encrypt(sub, []byte(rfcPlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decrypt(mustDecode(t, rfcUAPrivate), mustDecode(t, rfcAuthSecret), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != rfcPlaintext {
+		t.Errorf("decrypted %q, want %q", got, rfcPlaintext)
+	}
+}
+
+func TestEncryptPayloadLimit(t *testing.T) {
+	sub := Subscription{Endpoint: "https://push.example.net/x", P256dh: rfcUAPublic, Auth: rfcAuthSecret}
+
+	body, err := encrypt(sub, bytes.Repeat([]byte("a"), MaxPayloadSize))
+	if err != nil {
+		t.Fatalf("payload of MaxPayloadSize: %v", err)
+	}
+	if len(body) != 4096 {
+		t.Errorf("message of MaxPayloadSize is %d bytes, want 4096", len(body))
+	}
+
+	if _, err := encrypt(sub, bytes.Repeat([]byte("a"), MaxPayloadSize+1)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("payload over MaxPayloadSize: got %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+// decrypt opens a single record aes128gcm message the way a user agent does
+func decrypt(uaPrivateBytes, authSecret, body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("message too short")
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != recordSize {
+		return nil, errors.New("unexpected record size")
+	}
+	keyIDLen := int(body[20])
+	asPublicBytes := body[21 : 21+keyIDLen]
+	ciphertext := body[21+keyIDLen:]
+
+	uaPrivate, err := ecdh.P256().NewPrivateKey(uaPrivateBytes)
+	if err != nil {
+		return nil, err
+	}
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, err
+	}
+	ecdhSecret, err := uaPrivate.ECDH(asPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPrivate.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm, err := hkdf.Key(sha256.New, ecdhSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strip the padding back to the last record delimiter
+	end := bytes.LastIndexByte(plaintext, 0x02)
+	if end < 0 {
+		return nil, errors.New("missing padding delimiter")
+	}
+	return plaintext[:end], nil
+}
@@ -0,0 +1,158 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// retryBackoff is the delay before the second attempt, it doubles after each failure
+var retryBackoff = time.Second
+
+// ErrSubscriptionGone means the push service no longer knows the subscription and it should be removed
+var ErrSubscriptionGone = errors.New("push subscription is gone")
+
+// Subscription is a browser push subscription, as returned by PushSubscription.toJSON()
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// Client sends Web Push messages authenticated with VAPID (RFC 8292)
+type Client struct {
+	httpClient *http.Client
+	privateKey *ecdsa.PrivateKey
+	publicKey  string
+	subject    string
+	// Attempts is how many times a message is tried when the push service fails temporarily
+	Attempts int
+}
+
+// NewClient creates a client from a base64url VAPID key pair, as generated by common web-push tools.
+// subject is a mailto: or https: contact for the push service operator.
+func NewClient(publicKey, privateKey, subject string, httpClient *http.Client) (*Client, error) {
+	rawPrivate, err := decodeKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), rawPrivate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+
+	derivedPublic, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if publicKey != "" {
+		rawPublic, err := decodeKey(publicKey)
+		if err != nil || !bytes.Equal(rawPublic, derivedPublic) {
+			return nil, errors.New("VAPID public key does not match the private key")
+		}
+	}
+
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Client{
+		httpClient: httpClient,
+		privateKey: key,
+		publicKey:  base64.RawURLEncoding.EncodeToString(derivedPublic),
+		subject:    subject,
+		Attempts:   3,
+	}, nil
+}
+
+// PublicKey is the application server key browsers subscribe with
+func (c *Client) PublicKey() string {
+	return c.publicKey
+}
+
+// Send encrypts and delivers payload to the subscription, retrying with backoff while the push
+// service answers 429 or 5xx. ttl is how long the push service may hold the message for an offline device.
+func (c *Client) Send(ctx context.Context, sub Subscription, payload []byte, ttl time.Duration) error {
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := c.send(ctx, sub, body, ttl)
+		if err == nil || !retry || attempt >= c.Attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes one delivery attempt and reports whether a failure is worth retrying
+func (c *Client) send(ctx context.Context, sub Subscription, body []byte, ttl time.Duration) (bool, error) {
+	authorization, err := c.authorization(sub.Endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return false, ErrSubscriptionGone
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("push service responded %d", resp.StatusCode)
+	}
+	return false, fmt.Errorf("push service responded %d", resp.StatusCode)
+}
+
+// authorization builds the vapid Authorization header, a JWT scoped to the push service origin
+func (c *Client) authorization(endpoint string) (string, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpointURL.Scheme + "://" + endpointURL.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": c.subject,
+	})
+	signed, err := token.SignedString(c.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("vapid t=%s, k=%s", signed, c.publicKey), nil
+}
@@ -0,0 +1,107 @@
+package webpush
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient("", base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:ops@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSendDeliversEncryptedMessage(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Encoding"); got != "aes128gcm" {
+			t.Errorf("Content-Encoding %q, want aes128gcm", got)
+		}
+		if got := r.Header.Get("TTL"); got != "60" {
+			t.Errorf("TTL %q, want 60", got)
+		}
+		if got := r.Header.Get("Authorization"); !strings.HasPrefix(got, "vapid t=") {
+			t.Errorf("Authorization %q, want a vapid token", got)
+		}
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := newTestClient(t)
+	sub := Subscription{Endpoint: server.URL + "/push/abc", P256dh: rfcUAPublic, Auth: rfcAuthSecret}
+	if err := client.Send(context.Background(), sub, []byte(rfcPlaintext), time.Minute); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got, err := decrypt(mustDecode(t, rfcUAPrivate), mustDecode(t, rfcAuthSecret), received)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(got) != rfcPlaintext {
+		t.Errorf("push service got %q, want %q", got, rfcPlaintext)
+	}
+}
+
+func TestSendStatusHandling(t *testing.T) {
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = time.Second }()
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int32
+		wantGone     bool
+		wantErr      bool
+	}{
+		{name: "created", statuses: []int{201}, wantAttempts: 1},
+		{name: "not found prunes", statuses: []int{404}, wantAttempts: 1, wantGone: true, wantErr: true},
+		{name: "gone prunes", statuses: []int{410}, wantAttempts: 1, wantGone: true, wantErr: true},
+		{name: "too many requests is retried", statuses: []int{429, 201}, wantAttempts: 2},
+		{name: "server error is retried", statuses: []int{503, 500, 201}, wantAttempts: 3},
+		{name: "retries run out", statuses: []int{500, 502, 503, 201}, wantAttempts: 3, wantErr: true},
+		{name: "bad request is not retried", statuses: []int{400, 201}, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				w.WriteHeader(tt.statuses[min(int(n), len(tt.statuses))-1])
+			}))
+			defer server.Close()
+
+			client := newTestClient(t)
+			sub := Subscription{Endpoint: server.URL, P256dh: rfcUAPublic, Auth: rfcAuthSecret}
+			err := client.Send(context.Background(), sub, []byte("{}"), time.Minute)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send error %v, want error %t", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrSubscriptionGone) != tt.wantGone {
+				t.Errorf("Send error %v, want ErrSubscriptionGone %t", err, tt.wantGone)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}